@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jsiebens/hashi-up/pkg/installer"
+	"github.com/jsiebens/hashi-up/pkg/operator"
+	"github.com/jsiebens/hashi-up/scripts"
+	"github.com/muesli/coral"
+	"github.com/thanhpk/randstr"
+)
+
+func BackupScheduleCommand(product string) *coral.Command {
+
+	var every time.Duration
+	var keep int
+	var backupDir string
+	var remove bool
+
+	var command = &coral.Command{
+		Use:          "backup-schedule",
+		Short:        fmt.Sprintf("Schedule periodic snapshots of the %s state on a server via SSH", strings.Title(product)),
+		Long:         fmt.Sprintf("Install a systemd timer on a server via SSH that periodically saves a snapshot of the %s state in a local directory, keeping only the most recent snapshots", strings.Title(product)),
+		SilenceUsage: true,
+	}
+
+	var target = Target{}
+	target.prepareCommand(command)
+
+	var layout = Layout{}
+	layout.prepareConfigCommand(command, product)
+	layout.prepareBinCommand(command, product)
+
+	command.Flags().DurationVar(&every, "every", 6*time.Hour, "Interval between two snapshots")
+	command.Flags().IntVar(&keep, "keep", 28, "Number of most recent snapshots to keep")
+	command.Flags().StringVar(&backupDir, "backup-dir", fmt.Sprintf("/var/backups/%s", product), "Directory on the target to save the snapshots in")
+	command.Flags().BoolVar(&remove, "remove", false, "If set to true will remove the scheduled snapshots, the snapshots already taken are kept")
+
+	command.RunE = func(command *coral.Command, args []string) error {
+		if !target.Local && len(target.Addr) == 0 {
+			return fmt.Errorf("required ssh-target-addr flag is missing")
+		}
+
+		if err := layout.validate(); err != nil {
+			return err
+		}
+
+		if every < time.Minute {
+			return fmt.Errorf("invalid every '%s', an interval of at least one minute is required", every)
+		}
+
+		if keep < 1 {
+			return fmt.Errorf("invalid keep '%d', at least one snapshot must be kept", keep)
+		}
+
+		clean, err := installer.ValidateDir("backup-dir", backupDir)
+		if err != nil {
+			return err
+		}
+		backupDir = clean
+
+		action := "install"
+		if remove {
+			action = "remove"
+		}
+
+		callback := func(op operator.CommandOperator) error {
+			dir := "/tmp/hashi-up." + randstr.String(6)
+
+			defer op.Execute("rm -rf " + dir)
+
+			err := op.Execute("mkdir -p " + dir)
+			if err != nil {
+				return fmt.Errorf("error received during preparation: %s", err)
+			}
+
+			data := map[string]interface{}{
+				"Product":   product,
+				"Title":     strings.Title(product),
+				"ConfigDir": layout.ConfigDir,
+				"BinDir":    layout.BinDir,
+				"BackupDir": backupDir,
+				"Keep":      keep,
+				"Every":     every.String(),
+			}
+
+			jobScript, err := scripts.RenderScript("backup_job.sh", data)
+			if err != nil {
+				return err
+			}
+
+			scheduleScript, err := scripts.RenderScript("backup_schedule.sh", data)
+			if err != nil {
+				return err
+			}
+
+			err = op.Upload(jobScript, dir+"/backup.sh", "0700")
+			if err != nil {
+				return fmt.Errorf("error received during upload backup job: %s", err)
+			}
+
+			err = op.Upload(scheduleScript, dir+"/run.sh", "0755")
+			if err != nil {
+				return fmt.Errorf("error received during upload backup schedule script: %s", err)
+			}
+
+			if remove {
+				info(fmt.Sprintf("Removing scheduled snapshots of %s ...", strings.Title(product)))
+			} else {
+				info(fmt.Sprintf("Scheduling snapshots of %s ...", strings.Title(product)))
+			}
+			sudoPass, err := target.sudoPass()
+			if err != nil {
+				return fmt.Errorf("error received during backup schedule: %s", err)
+			}
+			err = op.Execute(fmt.Sprintf("cat %s/run.sh | TMP_DIR=%s ACTION=%s SERVICE=%s SUDO_PASS=\"%s\" sh -\n", dir, dir, action, product, sudoPass))
+			if err != nil {
+				return fmt.Errorf("error received during backup schedule: %s", err)
+			}
+
+			info("Done.")
+
+			return nil
+		}
+
+		return target.execute(callback)
+	}
+
+	return command
+}
@@ -2,9 +2,10 @@ package cmd
 
 import (
 	"fmt"
-	"strings"
+	"os"
 
 	"github.com/jsiebens/hashi-up/pkg/config"
+	"github.com/jsiebens/hashi-up/pkg/installer"
 	"github.com/jsiebens/hashi-up/pkg/operator"
 	"github.com/jsiebens/hashi-up/scripts"
 	"github.com/muesli/coral"
@@ -16,10 +17,15 @@ func InitBoundaryDatabaseCommand() *coral.Command {
 
 	var binary string
 	var version string
+	var localDownload bool
+	var skipDependencies bool
 
 	var configFile string
+	var renderOnly bool
+	var renderer = Renderer{}
 
 	var flags = config.BoundaryConfig{}
+	var layout = Layout{}
 
 	var command = &coral.Command{
 		Use:          "init-database",
@@ -31,22 +37,27 @@ func InitBoundaryDatabaseCommand() *coral.Command {
 
 	command.Flags().StringVar(&binary, "package", "", "Upload and use this Boundary package instead of downloading")
 	command.Flags().StringVarP(&version, "version", "v", "", "Version of Boundary to install")
+	command.Flags().BoolVar(&localDownload, "local-download", false, "If set to true will download and verify Boundary on this machine and upload the binary, instead of downloading it on the target")
+	layout.prepareBinCommand(command, "boundary")
+	command.Flags().BoolVar(&skipDependencies, "skip-dependencies", false, "If set to true will not install missing dependencies, like curl and unzip, on the target")
 
 	command.Flags().StringVarP(&configFile, "config-file", "c", "", "Custom Boundary configuration file to upload")
+	command.Flags().BoolVar(&renderOnly, "render-only", false, "If set to true will only render the Boundary database configuration without initializing the database")
+	renderer.prepareCommand(command)
 
 	command.Flags().StringVar(&flags.DatabaseURL, "db-url", "", "Boundary: configures the URL for connecting to Postgres")
 	command.Flags().StringVar(&flags.RootKey, "root-key", "", "Boundary: a KEK (Key Encrypting Key) for the scope-specific KEKs (also referred to as the scope's root key).")
 
 	command.RunE = func(command *coral.Command, args []string) error {
-		if !target.Local && len(target.Addr) == 0 {
-			return fmt.Errorf("required ssh-target-addr flag is missing")
-		}
+		var configuration *installer.Configuration
 
-		ignoreConfigFlags := len(configFile) != 0
-
-		var generatedConfig string
-
-		if !ignoreConfigFlags {
+		if len(configFile) != 0 {
+			var err error
+			configuration, err = installer.LoadConfiguration("boundary.hcl", configFile, nil)
+			if err != nil {
+				return err
+			}
+		} else {
 			if !flags.HasDatabaseURL() {
 				return fmt.Errorf("a db-url is required when initializing the database")
 			}
@@ -54,7 +65,23 @@ func InitBoundaryDatabaseCommand() *coral.Command {
 				return fmt.Errorf("a root-key when initializing the database")
 			}
 
-			generatedConfig = flags.GenerateDbConfigFile()
+			configuration = &installer.Configuration{Name: "boundary.hcl", Content: flags.GenerateDbConfigFile()}
+		}
+
+		if renderOnly {
+			return renderer.render(configuration, "")
+		}
+
+		if !target.Local && len(target.Addr) == 0 {
+			return fmt.Errorf("required ssh-target-addr flag is missing")
+		}
+
+		if err := layout.validate(); err != nil {
+			return err
+		}
+
+		if len(binary) != 0 && localDownload {
+			return fmt.Errorf("the package and local-download flags can not be used together")
 		}
 
 		if len(binary) == 0 && len(version) == 0 {
@@ -85,23 +112,21 @@ func InitBoundaryDatabaseCommand() *coral.Command {
 				}
 			}
 
-			if !ignoreConfigFlags {
-				info("Uploading generated Boundary configuration ...")
-				err = op.Upload(strings.NewReader(generatedConfig), dir+"/config/boundary.hcl", "0640")
-				if err != nil {
-					return fmt.Errorf("error received during upload boundary configuration: %s", err)
-				}
-			} else {
-				info(fmt.Sprintf("Uploading %s as boundary.hcl...", configFile))
-				err = op.UploadFile(expandPath(configFile), dir+"/config/boundary.hcl", "0640")
-				if err != nil {
-					return fmt.Errorf("error received during upload boundary configuration: %s", err)
+			if localDownload {
+				if err := installer.UploadBinary(op, dir, "boundary", version, os.Stdout); err != nil {
+					return err
 				}
 			}
 
+			if err := configuration.Upload(op, dir, "Boundary", os.Stdout); err != nil {
+				return err
+			}
+
 			data := map[string]interface{}{
-				"TmpDir":  dir,
-				"Version": version,
+				"TmpDir":           dir,
+				"Version":          version,
+				"BinDir":           layout.BinDir,
+				"SkipDependencies": skipDependencies,
 			}
 
 			installScript, err := scripts.RenderScript("install_boundary_db.sh", data)
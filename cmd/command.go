@@ -13,12 +13,22 @@ type Installer func() *coral.Command
 func Execute() error {
 
 	rootCmd := baseCommand("hashi-up")
+
+	var defaults = Defaults{}
+	defaults.prepareCommand(rootCmd)
+	rootCmd.PersistentPreRunE = func(cmd *coral.Command, args []string) error {
+		if err := defaults.apply(cmd); err != nil {
+			return err
+		}
+		return resolveSecretFlags(cmd)
+	}
+
 	rootCmd.AddCommand(TlsCommands())
 	rootCmd.AddCommand(VersionCommand())
-	rootCmd.AddCommand(productCommand("consul", InstallConsulCommand))
-	rootCmd.AddCommand(productCommand("nomad", InstallNomadCommand))
-	rootCmd.AddCommand(productCommand("vault", InstallVaultCommand))
-	rootCmd.AddCommand(productCommand("boundary", InstallBoundaryCommand, InitBoundaryDatabaseCommand))
+	rootCmd.AddCommand(productCommand("consul", InstallConsulCommand, ConsulConfigCommand, ConsulDiffCommand, ConsulSnapshotCommand, ConsulBackupScheduleCommand))
+	rootCmd.AddCommand(productCommand("nomad", InstallNomadCommand, NomadConfigCommand, NomadDiffCommand, NomadSnapshotCommand, NomadBackupScheduleCommand))
+	rootCmd.AddCommand(productCommand("vault", InstallVaultCommand, VaultConfigCommand, VaultDiffCommand, VaultSnapshotCommand, VaultBackupScheduleCommand))
+	rootCmd.AddCommand(productCommand("boundary", InstallBoundaryCommand, BoundaryConfigCommand, BoundaryDiffCommand, InitBoundaryDatabaseCommand))
 	rootCmd.AddCommand(productCommand("terraform"))
 	rootCmd.AddCommand(productCommand("packer"))
 	rootCmd.AddCommand(productCommand("vagrant"))
@@ -50,10 +60,14 @@ func productCommand(name string, installer ...Installer) *coral.Command {
 		for _, y := range installer {
 			command.AddCommand(y())
 		}
+		command.AddCommand(StatusCommand(name))
+		command.AddCommand(LogsCommand(name))
 		command.AddCommand(ManageServiceCommand("stop", name))
 		command.AddCommand(ManageServiceCommand("start", name))
 		command.AddCommand(ManageServiceCommand("restart", name))
 		command.AddCommand(ManageServiceCommand("reload", name))
+		command.AddCommand(UpgradeCommand(name))
+		command.AddCommand(RollbackCommand(name))
 		command.AddCommand(UninstallCommand(name))
 	}
 	return command
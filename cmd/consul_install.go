@@ -1,190 +1,119 @@
 package cmd
 
 import (
-	"fmt"
-	"path/filepath"
-	"strings"
+	"context"
 
 	"github.com/jsiebens/hashi-up/pkg/config"
-	"github.com/jsiebens/hashi-up/pkg/operator"
-	"github.com/jsiebens/hashi-up/scripts"
+	"github.com/jsiebens/hashi-up/pkg/installer"
 	"github.com/muesli/coral"
-	"github.com/pkg/errors"
-	"github.com/thanhpk/randstr"
 )
 
-func InstallConsulCommand() *coral.Command {
+func ConsulConfigCommand() *coral.Command {
+	return ConfigCommand("consul", &consulConfigFlags{})
+}
 
-	var skipConfig bool
-	var skipEnable bool
-	var skipStart bool
-	var binary string
-	var version string
+func ConsulDiffCommand() *coral.Command {
+	return DiffCommand("consul", &consulConfigFlags{})
+}
 
-	var configFile string
-	var files []string
+func ConsulSnapshotCommand() *coral.Command {
+	return SnapshotCommand("consul")
+}
 
-	var flags = config.ConsulConfig{}
+func ConsulBackupScheduleCommand() *coral.Command {
+	return BackupScheduleCommand("consul")
+}
 
-	var command = &coral.Command{
-		Use:          "install",
-		Short:        "Install Consul on a server via SSH",
-		Long:         "Install Consul on a server via SSH",
-		SilenceUsage: true,
-	}
+type consulConfigFlags struct {
+	configFile string
+	files      []string
+	settings   []string
+	format     string
+	flags      config.ConsulConfig
+	layout     Layout
+}
 
-	var target = Target{}
-	target.prepareCommand(command)
-
-	command.Flags().BoolVar(&skipConfig, "skip-config", false, "If set to true will install Consul service without touching existing config files")
-	command.Flags().BoolVar(&skipEnable, "skip-enable", false, "If set to true will not enable or start Consul service")
-	command.Flags().BoolVar(&skipStart, "skip-start", false, "If set to true will not start Consul service")
-	command.Flags().StringVar(&binary, "package", "", "Upload and use this Consul package instead of downloading")
-	command.Flags().StringVarP(&version, "version", "v", "", "Version of Consul to install")
-
-	command.Flags().StringVarP(&configFile, "config-file", "c", "", "Custom Consul configuration file to upload, setting this will disable config file generation meaning the other flags are ignored")
-	command.Flags().StringSliceVarP(&files, "file", "f", []string{}, "Additional files, e.g. certificates, to upload")
-
-	command.Flags().BoolVar(&flags.Server, "server", false, "Consul: switches agent to server mode. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.Datacenter, "datacenter", "dc1", "Consul: specifies the data center of the local agent. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.BindAddr, "bind-addr", "", "Consul: sets the bind address for cluster communication. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.AdvertiseAddr, "advertise-addr", "", "Consul: sets the advertise address to use. (see Consul documentation for more info)")
-
-	command.Flags().StringVar(&flags.ClientAddr, "client-addr", "", "Consul: sets the address to bind for client access. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.DnsAddr, "dns-addr", "", "Consul: sets the address for the DNS server. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.HttpAddr, "http-addr", "", "Consul: sets the address for the HTTP API server. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.HttpsAddr, "https-addr", "", "Consul: sets the address for the HTTPS API server. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.GrpcAddr, "grpc-addr", "", "Consul: sets the address for the gRPC API server. (see Consul documentation for more info)")
-
-	command.Flags().Int64Var(&flags.BootstrapExpect, "bootstrap-expect", 1, "Consul: sets server to expect bootstrap mode. 0 are less disables bootstrap mode. (see Consul documentation for more info)")
-	command.Flags().StringSliceVar(&flags.RetryJoin, "retry-join", []string{}, "Consul: address of an agent to join at start time with retries enabled. Can be specified multiple times. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.Encrypt, "encrypt", "", "Consul: provides the gossip encryption key. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.CaFile, "ca-file", "", "Consul: the certificate authority used to check the authenticity of client and server connections. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.CertFile, "cert-file", "", "Consul: the certificate to verify the agent's authenticity. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.KeyFile, "key-file", "", "Consul: the key used with the certificate to verify the agent's authenticity. (see Consul documentation for more info)")
-	command.Flags().BoolVar(&flags.AutoEncrypt, "auto-encrypt", false, "Consul: this option enables auto_encrypt and allows servers to automatically distribute certificates from the Connect CA to the clients. (see Consul documentation for more info)")
-	command.Flags().BoolVar(&flags.HttpsOnly, "https-only", true, "Consul: if true, HTTP port is disabled on both clients and servers and to only accept HTTPS connections when TLS enabled.")
-	command.Flags().BoolVar(&flags.EnableConnect, "connect", false, "Consul: enables the Connect feature on the agent. (see Consul documentation for more info)")
-	command.Flags().BoolVar(&flags.EnableACL, "acl", false, "Consul: enables Consul ACL system. (see Consul documentation for more info)")
-	command.Flags().StringVar(&flags.AgentToken, "agent-token", "", "Consul: the token that the agent will use for internal agent operations.. (see Consul documentation for more info)")
-
-	command.Flags().StringVar(&flags.ClientAddr, "client", "", "")
-	command.Flags().StringVar(&flags.BindAddr, "bind", "", "")
-	command.Flags().StringVar(&flags.AdvertiseAddr, "advertise", "", "")
+func (c *consulConfigFlags) prepareCommand(command *coral.Command) {
+	command.Flags().StringVarP(&c.configFile, "config-file", "c", "", "Custom Consul configuration file to upload, setting this will disable config file generation meaning the other flags are ignored")
+	command.Flags().StringSliceVarP(&c.files, "file", "f", []string{}, "Additional files, e.g. certificates, to upload")
+	command.Flags().StringArrayVar(&c.settings, "set", []string{}, "Set an arbitrary Consul configuration attribute in the generated configuration file, e.g. log_level=debug or ports.grpc=8502. Can be specified multiple times.")
+	command.Flags().StringVar(&c.format, "config-format", config.FormatHCL, "Format of the generated Consul configuration file, hcl or json, a config-file keeps its own format")
+	c.layout.prepareCommand(command, "consul")
+
+	command.Flags().BoolVar(&c.flags.Server, "server", false, "Consul: switches agent to server mode. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.Datacenter, "datacenter", "dc1", "Consul: specifies the data center of the local agent. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.BindAddr, "bind-addr", "", "Consul: sets the bind address for cluster communication. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.AdvertiseAddr, "advertise-addr", "", "Consul: sets the advertise address to use. (see Consul documentation for more info)")
+
+	command.Flags().StringVar(&c.flags.ClientAddr, "client-addr", "", "Consul: sets the address to bind for client access. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.DnsAddr, "dns-addr", "", "Consul: sets the address for the DNS server. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.HttpAddr, "http-addr", "", "Consul: sets the address for the HTTP API server. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.HttpsAddr, "https-addr", "", "Consul: sets the address for the HTTPS API server. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.GrpcAddr, "grpc-addr", "", "Consul: sets the address for the gRPC API server. (see Consul documentation for more info)")
+
+	command.Flags().Int64Var(&c.flags.BootstrapExpect, "bootstrap-expect", 1, "Consul: sets server to expect bootstrap mode. 0 are less disables bootstrap mode. (see Consul documentation for more info)")
+	command.Flags().StringSliceVar(&c.flags.RetryJoin, "retry-join", []string{}, "Consul: address of an agent to join at start time with retries enabled. Can be specified multiple times. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.Encrypt, "encrypt", "", "Consul: provides the gossip encryption key. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.CaFile, "ca-file", "", "Consul: the certificate authority used to check the authenticity of client and server connections. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.CertFile, "cert-file", "", "Consul: the certificate to verify the agent's authenticity. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.KeyFile, "key-file", "", "Consul: the key used with the certificate to verify the agent's authenticity. (see Consul documentation for more info)")
+	command.Flags().BoolVar(&c.flags.AutoEncrypt, "auto-encrypt", false, "Consul: this option enables auto_encrypt and allows servers to automatically distribute certificates from the Connect CA to the clients. (see Consul documentation for more info)")
+	command.Flags().BoolVar(&c.flags.HttpsOnly, "https-only", true, "Consul: if true, HTTP port is disabled on both clients and servers and to only accept HTTPS connections when TLS enabled.")
+	command.Flags().BoolVar(&c.flags.EnableConnect, "connect", false, "Consul: enables the Connect feature on the agent. (see Consul documentation for more info)")
+	command.Flags().BoolVar(&c.flags.EnableACL, "acl", false, "Consul: enables Consul ACL system. (see Consul documentation for more info)")
+	command.Flags().StringVar(&c.flags.AgentToken, "agent-token", "", "Consul: the token that the agent will use for internal agent operations.. (see Consul documentation for more info)")
+
+	command.Flags().StringVar(&c.flags.ClientAddr, "client", "", "")
+	command.Flags().StringVar(&c.flags.BindAddr, "bind", "", "")
+	command.Flags().StringVar(&c.flags.AdvertiseAddr, "advertise", "", "")
 	_ = command.Flags().MarkDeprecated("client", "use the new flag client-addr")
 	_ = command.Flags().MarkDeprecated("bind", "use the new flag bind-addr")
 	_ = command.Flags().MarkDeprecated("advertise", "use the new flag advertise-addr")
+}
+
+func (c *consulConfigFlags) configDir() string {
+	return c.layout.ConfigDir
+}
 
-	command.RunE = func(command *coral.Command, args []string) error {
-		if !target.Local && len(target.Addr) == 0 {
-			return fmt.Errorf("required ssh-target-addr flag is missing")
-		}
-
-		ignoreConfigFlags := skipConfig || len(configFile) != 0
-
-		var generatedConfig string
-
-		if !ignoreConfigFlags {
-			generatedConfig = flags.GenerateConfigFile()
-		}
-
-		if len(binary) == 0 && len(version) == 0 {
-			latest, err := config.GetLatestVersion("consul")
-
-			if err != nil {
-				return errors.Wrapf(err, "unable to get latest version number, define a version manually with the --version flag")
-			}
-
-			version = latest
-		}
-
-		callback := func(op operator.CommandOperator) error {
-			dir := "/tmp/hashi-up." + randstr.String(6)
-
-			defer op.Execute("rm -rf " + dir)
-
-			err := op.Execute("mkdir -p " + dir + "/config")
-			if err != nil {
-				return fmt.Errorf("error received during installation: %s", err)
-			}
-
-			if len(binary) != 0 {
-				info("Uploading Consul package ...")
-				err = op.UploadFile(binary, dir+"/consul.zip", "0640")
-				if err != nil {
-					return fmt.Errorf("error received during upload Consul package: %s", err)
-				}
-			}
-
-			if !skipConfig {
-				if !ignoreConfigFlags {
-					info("Uploading generated Consul configuration ...")
-					err = op.Upload(strings.NewReader(generatedConfig), dir+"/config/consul.hcl", "0640")
-					if err != nil {
-						return fmt.Errorf("error received during upload consul configuration: %s", err)
-					}
-
-					files = []string{}
-
-					if flags.EnableTLS() {
-						files = []string{flags.CaFile, flags.CertFile, flags.KeyFile}
-					}
-				} else {
-					info(fmt.Sprintf("Uploading %s as consul.hcl...", configFile))
-					err = op.UploadFile(expandPath(configFile), dir+"/config/consul.hcl", "0640")
-					if err != nil {
-						return fmt.Errorf("error received during upload consul configuration: %s", err)
-					}
-				}
-
-				for _, s := range files {
-					if len(s) != 0 {
-						info(fmt.Sprintf("Uploading %s...", s))
-						_, filename := filepath.Split(expandPath(s))
-						err = op.UploadFile(expandPath(s), dir+"/config/"+filename, "0640")
-						if err != nil {
-							return fmt.Errorf("error received during upload consul ca file: %s", err)
-						}
-					}
-				}
-			}
-
-			data := map[string]interface{}{
-				"TmpDir":     dir,
-				"SkipEnable": skipEnable,
-				"SkipStart":  skipStart,
-				"Version":    version,
-				"ArmSuffix":  config.GetArmSuffix("consul", version),
-			}
-
-			installScript, err := scripts.RenderScript("install_consul.sh", data)
-
-			if err != nil {
-				return err
-			}
-
-			err = op.Upload(installScript, dir+"/install.sh", "0755")
-			if err != nil {
-				return fmt.Errorf("error received during upload install script: %s", err)
-			}
-
-			info("Installing Consul ...")
-			sudoPass, err := target.sudoPass()
-			if err != nil {
-				return fmt.Errorf("error received during installation: %s", err)
-			}
-			err = op.Execute(fmt.Sprintf("cat %s/install.sh | SUDO_PASS=\"%s\" sh -\n", dir, sudoPass))
-			if err != nil {
-				return fmt.Errorf("error received during installation: %s", err)
-			}
-
-			info("Done.")
-
-			return nil
-		}
-
-		return target.execute(callback)
+func (c *consulConfigFlags) options() installer.Options {
+	return installer.Options{ConfigFile: c.configFile, Files: c.files, ConfigFormat: c.format, Layout: c.layout.Layout}
+}
+
+// prepare validates the layout and parses the settings of the generated configuration
+func (c *consulConfigFlags) prepare() error {
+	if err := c.layout.validate(); err != nil {
+		return err
 	}
 
+	if len(c.configFile) != 0 {
+		return nil
+	}
+
+	settings, err := config.ParseSettings(c.settings)
+	if err != nil {
+		return err
+	}
+	c.flags.Settings = settings
+
+	return nil
+}
+
+func (c *consulConfigFlags) resolve() (*installer.Configuration, error) {
+	if err := c.prepare(); err != nil {
+		return nil, err
+	}
+	return installer.ConsulConfiguration(c.flags, c.options())
+}
+
+func InstallConsulCommand() *coral.Command {
+	var flags = consulConfigFlags{}
+
+	command := installCommand("consul", &flags, func(ctx context.Context, target installer.Target, opts installer.Options) (*installer.Result, error) {
+		return installer.InstallConsul(ctx, target, flags.flags, opts)
+	})
+
+	flags.layout.prepareBinCommand(command, "consul")
+	flags.layout.prepareUserCommand(command, "consul")
+
 	return command
 }
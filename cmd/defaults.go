@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/muesli/coral"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultsFileName = ".hashi-up.yaml"
+
+// defaultAnnotation marks the flags set from an environment variable or a configuration file instead of the command line
+const defaultAnnotation = "hashi-up/default"
+
+// legacyEnv are the environment variables supported before every flag was bound to a HASHI_UP_* environment variable
+var legacyEnv = map[string]string{
+	"ssh-target-password":  "SSH_TARGET_PASSWORD",
+	"ssh-target-sudo-pass": "SSH_TARGET_SUDO_PASS",
+}
+
+// Defaults holds the flags selecting the hashi-up configuration file and profile with the defaults of the other flags
+type Defaults struct {
+	ConfigFile string
+	Profile    string
+}
+
+// defaultsFile is a hashi-up configuration file with flag values for every command, values per product,
+// like consul or nomad, and named profiles with the same layout
+type defaultsFile struct {
+	Defaults map[string]interface{}            `yaml:"defaults"`
+	Profiles map[string]*defaultsFile          `yaml:"profiles"`
+	Products map[string]map[string]interface{} `yaml:",inline"`
+}
+
+func (d *Defaults) prepareCommand(cmd *coral.Command) {
+	cmd.PersistentFlags().StringVar(&d.ConfigFile, "config", "", fmt.Sprintf("Configuration file with flag defaults, instead of ~/%s and %s in the current directory", defaultsFileName, defaultsFileName))
+	cmd.PersistentFlags().StringVar(&d.Profile, "profile", "", "Named profile of the configuration file to apply on top of its defaults")
+}
+
+// apply sets every flag of the command that is not set on the command line, from its HASHI_UP_* environment variable
+// or else from the configuration files, with the values of the profile and the product taking precedence
+func (d *Defaults) apply(cmd *coral.Command) error {
+	configFile := d.ConfigFile
+	if f := cmd.Flags().Lookup("config"); f != nil && !f.Changed {
+		configFile = os.Getenv(envName("config"))
+	}
+
+	profile := d.Profile
+	if f := cmd.Flags().Lookup("profile"); f != nil && !f.Changed {
+		profile = os.Getenv(envName("profile"))
+	}
+
+	file, err := loadDefaultsFiles(configFile)
+	if err != nil {
+		return err
+	}
+
+	values, err := file.values(productName(cmd), profile)
+	if err != nil {
+		return err
+	}
+
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" || f.Name == "config" || f.Name == "profile" {
+			return
+		}
+
+		if value, ok := lookupEnv(f.Name); ok {
+			if serr := cmd.Flags().Set(f.Name, value); serr != nil {
+				err = fmt.Errorf("invalid value '%s' for %s: %s", value, envName(f.Name), serr)
+				return
+			}
+			markDefault(f)
+			return
+		}
+
+		if value, ok := values[f.Name]; ok {
+			if serr := setFlagValue(cmd.Flags(), f, value); serr != nil {
+				err = fmt.Errorf("invalid value for %s in the configuration file: %s", f.Name, serr)
+				return
+			}
+			markDefault(f)
+		}
+	})
+
+	return err
+}
+
+func markDefault(f *pflag.Flag) {
+	if f.Annotations == nil {
+		f.Annotations = map[string][]string{}
+	}
+	f.Annotations[defaultAnnotation] = []string{"true"}
+}
+
+// isDefault reports whether a flag is set from an environment variable or a configuration file instead of the command line
+func isDefault(f *pflag.Flag) bool {
+	_, ok := f.Annotations[defaultAnnotation]
+	return ok
+}
+
+// envName returns the environment variable bound to a flag, e.g. HASHI_UP_SSH_TARGET_USER for --ssh-target-user
+func envName(flag string) string {
+	return "HASHI_UP_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+func lookupEnv(flag string) (string, bool) {
+	if value, ok := os.LookupEnv(envName(flag)); ok {
+		return value, true
+	}
+	if name, ok := legacyEnv[flag]; ok {
+		if value := os.Getenv(name); len(value) != 0 {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// setFlagValue sets a flag from a value of the configuration file, a list is only accepted by flags with multiple values
+func setFlagValue(flags *pflag.FlagSet, f *pflag.Flag, value interface{}) error {
+	list, ok := value.([]interface{})
+	if !ok {
+		return flags.Set(f.Name, fmt.Sprint(value))
+	}
+
+	s, ok := f.Value.(pflag.SliceValue)
+	if !ok {
+		return fmt.Errorf("a single value is expected")
+	}
+
+	var values []string
+	for _, v := range list {
+		values = append(values, fmt.Sprint(v))
+	}
+	if err := s.Replace(values); err != nil {
+		return err
+	}
+	f.Changed = true
+	return nil
+}
+
+// productName returns the product of a command, e.g. consul for hashi-up consul install
+func productName(cmd *coral.Command) string {
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		if !c.Parent().HasParent() {
+			return c.Name()
+		}
+	}
+	return ""
+}
+
+// loadDefaultsFiles loads the given configuration file, or ~/.hashi-up.yaml overridden by .hashi-up.yaml in the current directory
+func loadDefaultsFiles(path string) (*defaultsFile, error) {
+	if len(path) != 0 {
+		return loadDefaultsFile(expandPath(path), true)
+	}
+
+	result := &defaultsFile{}
+
+	var paths []string
+	if home, err := filepath.Abs(expandPath("~/" + defaultsFileName)); err == nil {
+		paths = append(paths, home)
+	}
+	if local, err := filepath.Abs(defaultsFileName); err == nil && (len(paths) == 0 || local != paths[0]) {
+		paths = append(paths, local)
+	}
+
+	for _, p := range paths {
+		file, err := loadDefaultsFile(p, false)
+		if err != nil {
+			return nil, err
+		}
+		result.merge(file)
+	}
+
+	return result, nil
+}
+
+func loadDefaultsFile(path string, required bool) (*defaultsFile, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return &defaultsFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read configuration file %s: %s", path, err)
+	}
+
+	file := &defaultsFile{}
+	if err := yaml.Unmarshal(content, file); err != nil {
+		return nil, fmt.Errorf("unable to parse configuration file %s: %s", path, err)
+	}
+	return file, nil
+}
+
+// merge overlays the values of another configuration file on top of the values of this one
+func (d *defaultsFile) merge(other *defaultsFile) {
+	d.Defaults = mergeValues(d.Defaults, other.Defaults)
+
+	for product, values := range other.Products {
+		if d.Products == nil {
+			d.Products = map[string]map[string]interface{}{}
+		}
+		d.Products[product] = mergeValues(d.Products[product], values)
+	}
+
+	for name, profile := range other.Profiles {
+		if d.Profiles == nil {
+			d.Profiles = map[string]*defaultsFile{}
+		}
+		if d.Profiles[name] == nil {
+			d.Profiles[name] = &defaultsFile{}
+		}
+		if profile != nil {
+			d.Profiles[name].merge(profile)
+		}
+	}
+}
+
+// values returns the flag values for a product, overlaying the defaults, the product, the profile defaults and the profile product values
+func (d *defaultsFile) values(product string, profile string) (map[string]interface{}, error) {
+	result := mergeValues(nil, d.Defaults)
+	result = mergeValues(result, d.Products[product])
+
+	if len(profile) != 0 {
+		p, ok := d.Profiles[profile]
+		if !ok || p == nil {
+			return nil, fmt.Errorf("profile '%s' not found in the configuration file", profile)
+		}
+		result = mergeValues(result, p.Defaults)
+		result = mergeValues(result, p.Products[product])
+	}
+
+	return result, nil
+}
+
+func mergeValues(values map[string]interface{}, other map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	for k, v := range other {
+		values[k] = v
+	}
+	return values
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jsiebens/hashi-up/pkg/config"
+	"github.com/jsiebens/hashi-up/pkg/installer"
+	"github.com/jsiebens/hashi-up/pkg/operator"
+	"github.com/muesli/coral"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// stringAttribute matches an HCL or JSON line holding an attribute with a string value
+var stringAttribute = regexp.MustCompile(`^(\s*"?)([A-Za-z0-9_-]+)("?\s*[=:]\s*)"(?:[^"\\]|\\.)*"(,?\s*)$`)
+
+// DriftError is returned when the diff command detects drift, main exits with a distinct status for it
+type DriftError struct {
+	Product string
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("configuration drift detected for %s", strings.Title(e.Product))
+}
+
+// maskSecrets replaces the values of the sensitive attributes of a configuration file, so the diff can be printed in CI logs
+func maskSecrets(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, l := range lines {
+		if m := stringAttribute.FindStringSubmatch(l); m != nil && config.SensitiveAttribute(m[2]) {
+			lines[i] = m[1] + m[2] + m[3] + `"(sensitive value)"` + m[4]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func DiffCommand(product string, flags configFlags) *coral.Command {
+
+	var command = &coral.Command{
+		Use:          "diff",
+		Short:        fmt.Sprintf("Compare the generated %s configuration with the configuration installed on a server via SSH", strings.Title(product)),
+		Long:         fmt.Sprintf("Compare the generated %s configuration with the configuration installed on a server via SSH, exits with status 2 when drift is detected and with status 1 on errors", strings.Title(product)),
+		SilenceUsage: true,
+	}
+
+	var target = Target{}
+	target.prepareCommand(command)
+	flags.prepareCommand(command)
+
+	command.RunE = func(command *coral.Command, args []string) error {
+		if !target.Local && len(target.Addr) == 0 {
+			return fmt.Errorf("required ssh-target-addr flag is missing")
+		}
+
+		configuration, err := flags.resolve()
+		if err != nil {
+			return err
+		}
+
+		configDir := flags.configDir()
+		drift := false
+
+		callback := func(op operator.CommandOperator) error {
+			remotePath := configDir + "/" + configuration.Name
+
+			cat, err := target.sudoCommand(fmt.Sprintf("cat %s 2>/dev/null || true", remotePath))
+			if err != nil {
+				return err
+			}
+
+			res, err := op.ExecuteWithOutput(cat)
+			if err != nil {
+				return fmt.Errorf("error received while fetching %s: %s", remotePath, err)
+			}
+
+			if string(res.StdOut) != configuration.Content {
+				drift = true
+
+				diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+					A:        difflib.SplitLines(maskSecrets(string(res.StdOut))),
+					B:        difflib.SplitLines(maskSecrets(configuration.Content)),
+					FromFile: remotePath,
+					ToFile:   configuration.Name + " (generated)",
+					Context:  3,
+				})
+				if err != nil {
+					return err
+				}
+
+				if len(diff) != 0 {
+					fmt.Print(diff)
+				} else {
+					fmt.Printf("%s: sensitive values differ\n", remotePath)
+				}
+			}
+
+			for _, s := range configuration.Files {
+				if len(s) == 0 {
+					continue
+				}
+
+				_, filename := filepath.Split(expandPath(s))
+				remoteFile := configDir + "/" + filename
+
+				localHash, err := installer.FileHash(s)
+				if err != nil {
+					return err
+				}
+
+				sum, err := target.sudoCommand(fmt.Sprintf("sha256sum %s 2>/dev/null || true", remoteFile))
+				if err != nil {
+					return err
+				}
+
+				res, err := op.ExecuteWithOutput(sum)
+				if err != nil {
+					return fmt.Errorf("error received while hashing %s: %s", remoteFile, err)
+				}
+
+				var remoteHash string
+				if fields := strings.Fields(string(res.StdOut)); len(fields) != 0 {
+					remoteHash = fields[0]
+				}
+
+				switch {
+				case len(remoteHash) == 0:
+					drift = true
+					fmt.Printf("%s: missing on target, local sha256 %s\n", remoteFile, localHash)
+				case remoteHash != localHash:
+					drift = true
+					fmt.Printf("%s: differs, local sha256 %s, target sha256 %s\n", remoteFile, localHash, remoteHash)
+				default:
+					fmt.Printf("%s: up to date, sha256 %s\n", remoteFile, localHash)
+				}
+			}
+
+			return nil
+		}
+
+		if err := target.execute(callback); err != nil {
+			return err
+		}
+
+		if drift {
+			return &DriftError{Product: product}
+		}
+
+		info("No configuration drift detected.")
+
+		return nil
+	}
+
+	return command
+}
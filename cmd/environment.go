@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/muesli/coral"
+)
+
+// Environment holds the environment variables written to the environment file of a product service,
+// keeping secrets like tokens out of the configuration files
+type Environment struct {
+	Vars []string
+	File string
+}
+
+func (e *Environment) prepareCommand(cmd *coral.Command, title string) {
+	cmd.Flags().StringArrayVar(&e.Vars, "env", []string{}, fmt.Sprintf("Environment variable, in the form KEY=VALUE, to add to the %s service environment file. Can be specified multiple times.", title))
+	cmd.Flags().StringVar(&e.File, "env-file", "", fmt.Sprintf("Local file with environment variables, in the form KEY=VALUE, to add to the %s service environment file", title))
+}
+
+// content returns the environment file content, or an empty string when no environment variables are set
+func (e *Environment) content() (string, error) {
+	if len(e.Vars) == 0 && len(e.File) == 0 {
+		return "", nil
+	}
+
+	var lines []string
+
+	if len(e.File) != 0 {
+		content, err := os.ReadFile(expandPath(e.File))
+		if err != nil {
+			return "", fmt.Errorf("unable to read environment file %s: %s", e.File, err)
+		}
+		for _, l := range strings.Split(string(content), "\n") {
+			if len(strings.TrimSpace(l)) != 0 {
+				lines = append(lines, strings.TrimRight(l, "\r"))
+			}
+		}
+	}
+
+	for _, v := range e.Vars {
+		key, _, found := strings.Cut(v, "=")
+		if !found || len(strings.TrimSpace(key)) == 0 {
+			return "", fmt.Errorf("invalid environment variable '%s', expected format is KEY=VALUE", v)
+		}
+		lines = append(lines, v)
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
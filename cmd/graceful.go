@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/muesli/coral"
+)
+
+// GracefulStop takes a product out of its cluster before its service is stopped: a Consul agent leaves, a Nomad client
+// is drained and an active Vault node steps down
+type GracefulStop struct {
+	Force         bool
+	DrainDeadline time.Duration
+}
+
+func (g *GracefulStop) prepareCommand(cmd *coral.Command, product string) {
+	if product == "boundary" {
+		// Boundary gracefully shuts down on the stop signal itself
+		return
+	}
+	cmd.Flags().BoolVar(&g.Force, "force", false, fmt.Sprintf("If set to true will stop the %s service without gracefully leaving the cluster first", strings.Title(product)))
+	if product == "nomad" {
+		cmd.Flags().DurationVar(&g.DrainDeadline, "drain-deadline", 5*time.Minute, "Maximum time to wait for the allocations of a Nomad client to be drained before stopping the service")
+	}
+}
+
+func (g *GracefulStop) validate() error {
+	if g.DrainDeadline < 0 {
+		return fmt.Errorf("invalid drain-deadline '%s', a positive duration is required", g.DrainDeadline)
+	}
+	return nil
+}
+
+// apply adds the graceful stop options to the data of a rendered script
+func (g *GracefulStop) apply(data map[string]interface{}) map[string]interface{} {
+	data["Force"] = g.Force
+	data["DrainDeadline"] = g.DrainDeadline.String()
+	return data
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jsiebens/hashi-up/pkg/config"
+	"github.com/muesli/coral"
+)
+
+// HealthCheck waits on the target until a product reports healthy after its service is (re)started
+type HealthCheck struct {
+	Timeout time.Duration
+	NoWait  bool
+}
+
+func (h *HealthCheck) prepareCommand(cmd *coral.Command, title string) {
+	cmd.Flags().DurationVar(&h.Timeout, "wait-timeout", 60*time.Second, fmt.Sprintf("Maximum time to wait for %s to become healthy after the service is (re)started", title))
+	cmd.Flags().BoolVar(&h.NoWait, "no-wait", false, fmt.Sprintf("If set to true will not wait for %s to become healthy after the service is (re)started", title))
+}
+
+func (h *HealthCheck) validate() error {
+	if !h.NoWait && h.Timeout < time.Second {
+		return fmt.Errorf("invalid wait-timeout '%s', at least 1s is required", h.Timeout)
+	}
+	return nil
+}
+
+// apply adds the health check to the data of a rendered script, without an endpoint the scripts only verify the service keeps running
+func (h *HealthCheck) apply(data map[string]interface{}, endpoint config.HealthEndpoint) map[string]interface{} {
+	data["Wait"] = !h.NoWait
+	data["WaitTimeout"] = int(h.Timeout.Seconds())
+	data["Health"] = endpoint
+	return data
+}
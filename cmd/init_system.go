@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/jsiebens/hashi-up/pkg/installer"
+	"github.com/muesli/coral"
+)
+
+// InitSystem selects the init system managing the product service on the target, auto detected by the scripts by default
+type InitSystem struct {
+	Name string
+}
+
+func (i *InitSystem) prepareCommand(cmd *coral.Command) {
+	cmd.Flags().StringVar(&i.Name, "init-system", "auto", "Init system managing the service on the target, one of auto, systemd, openrc or sysv")
+}
+
+func (i *InitSystem) validate() error {
+	return installer.ValidateInitSystem(i.Name)
+}
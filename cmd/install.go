@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jsiebens/hashi-up/pkg/installer"
+	"github.com/muesli/coral"
+)
+
+// Install holds the flags shared by the install commands of all products, the installation itself is done by the installer package
+type Install struct {
+	Target      Target
+	Environment Environment
+	Service     ServiceOptions
+	InitSystem  InitSystem
+	Health      HealthCheck
+	Record      Record
+
+	SkipConfig       bool
+	SkipEnable       bool
+	SkipStart        bool
+	SkipValidate     bool
+	SkipDependencies bool
+	Package          string
+	Version          string
+	LocalDownload    bool
+}
+
+func (i *Install) prepareCommand(cmd *coral.Command, title string) {
+	i.Target.prepareCommand(cmd)
+	i.Environment.prepareCommand(cmd, title)
+	i.Service.prepareCommand(cmd, title)
+	i.InitSystem.prepareCommand(cmd)
+	i.Health.prepareCommand(cmd, title)
+	i.Record.prepareCommand(cmd, title)
+
+	cmd.Flags().BoolVar(&i.SkipConfig, "skip-config", false, fmt.Sprintf("If set to true will install %s service without touching existing config files", title))
+	cmd.Flags().BoolVar(&i.SkipEnable, "skip-enable", false, fmt.Sprintf("If set to true will not enable or start %s service", title))
+	cmd.Flags().BoolVar(&i.SkipStart, "skip-start", false, fmt.Sprintf("If set to true will not start %s service", title))
+	cmd.Flags().BoolVar(&i.SkipValidate, "skip-validate", false, fmt.Sprintf("If set to true will not validate the %s configuration on the target before (re)starting the service", title))
+	cmd.Flags().BoolVar(&i.SkipDependencies, "skip-dependencies", false, "If set to true will not install missing dependencies, like curl and unzip, on the target")
+	cmd.Flags().StringVar(&i.Package, "package", "", fmt.Sprintf("Upload and use this %s package instead of downloading", title))
+	cmd.Flags().StringVarP(&i.Version, "version", "v", "", fmt.Sprintf("Version of %s to install", title))
+	cmd.Flags().BoolVar(&i.LocalDownload, "local-download", false, fmt.Sprintf("If set to true will download and verify %s on this machine and upload the binary, instead of downloading it on the target", title))
+}
+
+// run reuses the flags of a previous installation when requested and installs the product with the given installer function
+func (i *Install) run(cmd *coral.Command, product string, flags configFlags, install func(context.Context, installer.Target, installer.Options) (*installer.Result, error)) error {
+	if !i.Target.Local && len(i.Target.Addr) == 0 {
+		return fmt.Errorf("required ssh-target-addr flag is missing")
+	}
+
+	if err := i.Record.reuse(cmd, &i.Target, product); err != nil {
+		return err
+	}
+
+	if err := flags.prepare(); err != nil {
+		return err
+	}
+
+	env, err := i.Environment.content()
+	if err != nil {
+		return err
+	}
+
+	service, err := i.Service.service()
+	if err != nil {
+		return err
+	}
+
+	target, err := i.Target.installerTarget()
+	if err != nil {
+		return err
+	}
+
+	opts := flags.options()
+	if f := cmd.Flags().Lookup("config-format"); f != nil && len(opts.ConfigFile) != 0 && (!f.Changed || isDefault(f)) {
+		// a configuration file keeps its own format, only a format set on the command line has to match it
+		opts.ConfigFormat = ""
+	}
+	opts.Version = i.Version
+	opts.Package = i.Package
+	opts.LocalDownload = i.LocalDownload
+	opts.SkipConfig = i.SkipConfig
+	opts.SkipEnable = i.SkipEnable
+	opts.SkipStart = i.SkipStart
+	opts.SkipValidate = i.SkipValidate
+	opts.SkipDependencies = i.SkipDependencies
+	opts.InitSystem = i.InitSystem.Name
+	opts.Environment = env
+	opts.Service = service
+	opts.NoWait = i.Health.NoWait
+	opts.WaitTimeout = i.Health.Timeout
+	opts.Record = i.Record.record(cmd)
+	opts.Stdout = os.Stdout
+	opts.Stderr = os.Stderr
+
+	if _, err := install(cmd.Context(), target, opts); err != nil {
+		return err
+	}
+
+	info("Done.")
+
+	return nil
+}
+
+// installCommand returns the install command of a product, with the flags of its configuration
+func installCommand(product string, flags configFlags, install func(context.Context, installer.Target, installer.Options) (*installer.Result, error)) *coral.Command {
+	title := strings.Title(product)
+
+	var command = &coral.Command{
+		Use:          "install",
+		Short:        fmt.Sprintf("Install %s on a server via SSH", title),
+		Long:         fmt.Sprintf("Install %s on a server via SSH", title),
+		SilenceUsage: true,
+	}
+
+	var i = Install{}
+	i.prepareCommand(command, title)
+
+	flags.prepareCommand(command)
+
+	command.RunE = func(command *coral.Command, args []string) error {
+		return i.run(command, product, flags, install)
+	}
+
+	return command
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jsiebens/hashi-up/pkg/installer"
+	"github.com/muesli/coral"
+)
+
+// Layout holds the directories and the system user and group of a product installation on the target
+type Layout struct {
+	installer.Layout
+}
+
+func (l *Layout) prepareCommand(cmd *coral.Command, product string) {
+	cmd.Flags().StringVar(&l.DataDir, "data-dir", fmt.Sprintf("/opt/%s", product), fmt.Sprintf("Directory on the target where %s stores its data", strings.Title(product)))
+	l.prepareConfigCommand(cmd, product)
+}
+
+func (l *Layout) prepareConfigCommand(cmd *coral.Command, product string) {
+	cmd.Flags().StringVar(&l.ConfigDir, "config-dir", fmt.Sprintf("/etc/%s.d", product), fmt.Sprintf("Directory on the target for the %s configuration files", strings.Title(product)))
+}
+
+func (l *Layout) prepareBinCommand(cmd *coral.Command, product string) {
+	cmd.Flags().StringVar(&l.BinDir, "bin-dir", "/usr/local/bin", fmt.Sprintf("Directory on the target for the %s binary", strings.Title(product)))
+}
+
+// prepareUserCommand registers the flags for the system user and group, a user without default means the service runs as root
+func (l *Layout) prepareUserCommand(cmd *coral.Command, product string) {
+	user := installer.DefaultUser(product)
+	usage := fmt.Sprintf("System user running the %s service", strings.Title(product))
+	if len(user) == 0 {
+		usage = usage + ", when empty the service runs as root"
+	}
+	cmd.Flags().StringVar(&l.User, "user", user, usage)
+	cmd.Flags().StringVar(&l.Group, "group", "", fmt.Sprintf("System group running the %s service, defaults to the name of the user", strings.Title(product)))
+}
+
+func (l *Layout) validate() error {
+	return l.Layout.Validate()
+}
+
+// env returns the layout as environment variables for the scripts configured through the environment
+func (l *Layout) env() string {
+	return fmt.Sprintf("DATA_DIR=%s CONFIG_DIR=%s BIN_DIR=%s", l.DataDir, l.ConfigDir, l.BinDir)
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jsiebens/hashi-up/pkg/operator"
+	"github.com/jsiebens/hashi-up/scripts"
+	"github.com/muesli/coral"
+	"github.com/thanhpk/randstr"
+)
+
+func LogsCommand(product string) *coral.Command {
+
+	var follow bool
+	var since string
+	var lines int
+	var grep string
+
+	var command = &coral.Command{
+		Use:          "logs",
+		Short:        fmt.Sprintf("Show the logs of the %s service on one or more servers via SSH", strings.Title(product)),
+		Long:         fmt.Sprintf("Show the logs of the %s service, from the journal or the log file of the init system, on one or more servers via SSH", strings.Title(product)),
+		SilenceUsage: true,
+	}
+
+	var targets = Targets{}
+	targets.prepareCommand(command)
+
+	var initSystem = InitSystem{}
+	initSystem.prepareCommand(command)
+
+	command.Flags().BoolVarP(&follow, "follow", "f", false, "Keep streaming new log lines until interrupted")
+	command.Flags().StringVar(&since, "since", "", "Only show log lines since the given time, e.g. \"2022-05-01 10:00\" or \"1 hour ago\" (systemd only)")
+	command.Flags().IntVarP(&lines, "lines", "n", 50, "Number of most recent log lines to show")
+	command.Flags().StringVarP(&grep, "grep", "g", "", "Only show log lines matching the given regular expression")
+
+	command.RunE = func(command *coral.Command, args []string) error {
+		if err := targets.validate(); err != nil {
+			return err
+		}
+
+		if err := initSystem.validate(); err != nil {
+			return err
+		}
+
+		if lines < 0 {
+			return fmt.Errorf("invalid lines '%d', a positive number is required", lines)
+		}
+
+		env := fmt.Sprintf("SERVICE=%s LOG_LINES=%d LOG_FOLLOW=%t LOG_SINCE=%s LOG_GREP=%s", product, lines, follow, shellQuote(since), shellQuote(grep))
+
+		all := targets.each()
+		lock := &sync.Mutex{}
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(all))
+
+		for i := range all {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				target := all[i]
+
+				// prefix the lines with the target only when showing the logs of several targets
+				var prefix string
+				if len(all) > 1 {
+					prefix = "[" + target.name() + "] "
+				}
+				stdout := newPrefixWriter(os.Stdout, prefix, lock)
+				stderr := newPrefixWriter(os.Stderr, prefix, lock)
+
+				callback := func(op operator.CommandOperator) error {
+					defer stdout.Flush()
+					defer stderr.Flush()
+					return streamLogs(op, &target, initSystem, env, stdout, stderr)
+				}
+
+				if err := target.execute(callback); err != nil {
+					errs[i] = fmt.Errorf("%s: %s", target.name(), err)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+
+		var failed []string
+		for _, err := range errs {
+			if err != nil {
+				failed = append(failed, err.Error())
+			}
+		}
+
+		if len(failed) != 0 {
+			return fmt.Errorf("unable to get the logs of %s: %s", strings.Title(product), strings.Join(failed, ", "))
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+func streamLogs(op operator.CommandOperator, target *Target, initSystem InitSystem, env string, stdout io.Writer, stderr io.Writer) error {
+	dir := "/tmp/hashi-up." + randstr.String(6)
+
+	defer op.Execute("rm -rf " + dir)
+
+	err := op.Execute("mkdir -p " + dir)
+	if err != nil {
+		return fmt.Errorf("error received during preparation: %s", err)
+	}
+
+	data := map[string]interface{}{
+		"InitSystem": initSystem.Name,
+	}
+
+	logsScript, err := scripts.RenderScript("logs.sh", data)
+	if err != nil {
+		return err
+	}
+
+	err = op.Upload(logsScript, dir+"/run.sh", "0755")
+	if err != nil {
+		return fmt.Errorf("error received during upload logs script: %s", err)
+	}
+
+	sudoPass, err := target.sudoPass()
+	if err != nil {
+		return fmt.Errorf("error received during execution: %s", err)
+	}
+	err = op.ExecuteWithWriter(fmt.Sprintf("cat %s/run.sh | %s SUDO_PASS=\"%s\" sh -\n", dir, env, sudoPass), stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("error received during execution: %s", err)
+	}
+
+	return nil
+}
+
+// prefixWriter writes complete lines, starting with a prefix, to the underlying writer, sharing a lock with the
+// writers of other targets so lines of different targets don't get mixed
+type prefixWriter struct {
+	out    io.Writer
+	prefix string
+	lock   *sync.Mutex
+	buf    []byte
+}
+
+func newPrefixWriter(out io.Writer, prefix string, lock *sync.Mutex) *prefixWriter {
+	return &prefixWriter{out: out, prefix: prefix, lock: lock}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush writes a remaining incomplete line
+func (w *prefixWriter) Flush() {
+	if len(w.buf) != 0 {
+		_ = w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+	return err
+}
@@ -1,172 +1,103 @@
 package cmd
 
 import (
-	"fmt"
-	"path/filepath"
-	"strings"
+	"context"
 
 	"github.com/jsiebens/hashi-up/pkg/config"
-	"github.com/jsiebens/hashi-up/pkg/operator"
-	"github.com/jsiebens/hashi-up/scripts"
+	"github.com/jsiebens/hashi-up/pkg/installer"
 	"github.com/muesli/coral"
-	"github.com/pkg/errors"
-	"github.com/thanhpk/randstr"
 )
 
-func InstallNomadCommand() *coral.Command {
+func NomadConfigCommand() *coral.Command {
+	return ConfigCommand("nomad", &nomadConfigFlags{})
+}
+
+func NomadDiffCommand() *coral.Command {
+	return DiffCommand("nomad", &nomadConfigFlags{})
+}
+
+func NomadSnapshotCommand() *coral.Command {
+	return SnapshotCommand("nomad")
+}
+
+func NomadBackupScheduleCommand() *coral.Command {
+	return BackupScheduleCommand("nomad")
+}
+
+type nomadConfigFlags struct {
+	configFile string
+	files      []string
+	settings   []string
+	format     string
+	flags      config.NomadConfig
+	layout     Layout
+}
+
+func (c *nomadConfigFlags) prepareCommand(command *coral.Command) {
+	command.Flags().StringVarP(&c.configFile, "config-file", "c", "", "Custom Nomad configuration file to upload, setting this will disable config file generation meaning the other flags are ignored")
+	command.Flags().StringSliceVarP(&c.files, "file", "f", []string{}, "Additional files, e.g. certificates, to upload")
+	command.Flags().StringArrayVar(&c.settings, "set", []string{}, "Set an arbitrary Nomad configuration attribute in the generated configuration file, e.g. log_level=debug or telemetry.disable_hostname=true. Can be specified multiple times.")
+	command.Flags().StringVar(&c.format, "config-format", config.FormatHCL, "Format of the generated Nomad configuration file, hcl or json, a config-file keeps its own format")
+	c.layout.prepareCommand(command, "nomad")
+
+	command.Flags().BoolVar(&c.flags.Server, "server", false, "Nomad: enables the server mode of the agent. (see Nomad documentation for more info)")
+	command.Flags().BoolVar(&c.flags.Client, "client", false, "Nomad: enables the client mode of the agent. (see Nomad documentation for more info)")
+	command.Flags().StringVar(&c.flags.Datacenter, "datacenter", "dc1", "Nomad: specifies the data center of the local agent. (see Nomad documentation for more info)")
+	command.Flags().StringVar(&c.flags.NodeClass, "node-class", "", "Nomad: specifies an arbitrary string used to logically group client nodes by user-defined class. (see Nomad documentation for more info)")
+	command.Flags().StringVar(&c.flags.BindAddr, "address", "", "Nomad: the address the agent will bind to for all of its various network services. (see Nomad documentation for more info)")
+	command.Flags().StringVar(&c.flags.AdvertiseAddr, "advertise", "", "Nomad: the address the agent will advertise to for all of its various network services. (see Nomad documentation for more info)")
+	command.Flags().Int64Var(&c.flags.BootstrapExpect, "bootstrap-expect", 1, "Nomad: sets server to expect bootstrap mode. 0 are less disables bootstrap mode. (see Nomad documentation for more info)")
+	command.Flags().StringSliceVar(&c.flags.RetryJoin, "retry-join", []string{}, "Nomad: address of an agent to join at start time with retries enabled. Can be specified multiple times. (see Nomad documentation for more info)")
+	command.Flags().StringVar(&c.flags.Encrypt, "encrypt", "", "Nomad: Provides the gossip encryption key. (see Nomad documentation for more info)")
+	command.Flags().StringVar(&c.flags.CaFile, "ca-file", "", "Nomad: the certificate authority used to check the authenticity of client and server connections. (see Nomad documentation for more info)")
+	command.Flags().StringVar(&c.flags.CertFile, "cert-file", "", "Nomad: the certificate to verify the agent's authenticity. (see Nomad documentation for more info)")
+	command.Flags().StringVar(&c.flags.KeyFile, "key-file", "", "Nomad: the key used with the certificate to verify the agent's authenticity. (see Nomad documentation for more info)")
+	command.Flags().BoolVar(&c.flags.EnableACL, "acl", false, "Nomad: enables Nomad ACL system. (see Nomad documentation for more info)")
+}
 
-	var skipConfig bool
-	var skipEnable bool
-	var skipStart bool
-	var binary string
-	var version string
+func (c *nomadConfigFlags) configDir() string {
+	return c.layout.ConfigDir
+}
+
+func (c *nomadConfigFlags) options() installer.Options {
+	return installer.Options{ConfigFile: c.configFile, Files: c.files, ConfigFormat: c.format, Layout: c.layout.Layout}
+}
 
-	var configFile string
-	var files []string
+// prepare validates the layout and parses the settings of the generated configuration
+func (c *nomadConfigFlags) prepare() error {
+	if err := c.layout.validate(); err != nil {
+		return err
+	}
 
-	var flags = config.NomadConfig{}
+	if len(c.configFile) != 0 {
+		return nil
+	}
 
-	var command = &coral.Command{
-		Use:          "install",
-		Short:        "Install Nomad on a server via SSH",
-		Long:         "Install Nomad on a server via SSH",
-		SilenceUsage: true,
+	settings, err := config.ParseSettings(c.settings)
+	if err != nil {
+		return err
 	}
+	c.flags.Settings = settings
+
+	return nil
+}
 
-	var target = Target{}
-	target.prepareCommand(command)
-
-	command.Flags().BoolVar(&skipConfig, "skip-config", false, "If set to true will install Nomad service without touching existing config files")
-	command.Flags().BoolVar(&skipEnable, "skip-enable", false, "If set to true will not enable or start Nomad service")
-	command.Flags().BoolVar(&skipStart, "skip-start", false, "If set to true will not start Nomad service")
-	command.Flags().StringVar(&binary, "package", "", "Upload and use this Nomad package instead of downloading")
-	command.Flags().StringVarP(&version, "version", "v", "", "Version of Nomad to install")
-
-	command.Flags().StringVarP(&configFile, "config-file", "c", "", "Custom Nomad configuration file to upload, setting this will disable config file generation meaning the other flags are ignored")
-	command.Flags().StringSliceVarP(&files, "file", "f", []string{}, "Additional files, e.g. certificates, to upload")
-
-	command.Flags().BoolVar(&flags.Server, "server", false, "Nomad: enables the server mode of the agent. (see Nomad documentation for more info)")
-	command.Flags().BoolVar(&flags.Client, "client", false, "Nomad: enables the client mode of the agent. (see Nomad documentation for more info)")
-	command.Flags().StringVar(&flags.Datacenter, "datacenter", "dc1", "Nomad: specifies the data center of the local agent. (see Nomad documentation for more info)")
-	command.Flags().StringVar(&flags.NodeClass, "node-class", "", "Nomad: specifies an arbitrary string used to logically group client nodes by user-defined class. (see Nomad documentation for more info)")
-	command.Flags().StringVar(&flags.BindAddr, "address", "", "Nomad: the address the agent will bind to for all of its various network services. (see Nomad documentation for more info)")
-	command.Flags().StringVar(&flags.AdvertiseAddr, "advertise", "", "Nomad: the address the agent will advertise to for all of its various network services. (see Nomad documentation for more info)")
-	command.Flags().Int64Var(&flags.BootstrapExpect, "bootstrap-expect", 1, "Nomad: sets server to expect bootstrap mode. 0 are less disables bootstrap mode. (see Nomad documentation for more info)")
-	command.Flags().StringSliceVar(&flags.RetryJoin, "retry-join", []string{}, "Nomad: address of an agent to join at start time with retries enabled. Can be specified multiple times. (see Nomad documentation for more info)")
-	command.Flags().StringVar(&flags.Encrypt, "encrypt", "", "Nomad: Provides the gossip encryption key. (see Nomad documentation for more info)")
-	command.Flags().StringVar(&flags.CaFile, "ca-file", "", "Nomad: the certificate authority used to check the authenticity of client and server connections. (see Nomad documentation for more info)")
-	command.Flags().StringVar(&flags.CertFile, "cert-file", "", "Nomad: the certificate to verify the agent's authenticity. (see Nomad documentation for more info)")
-	command.Flags().StringVar(&flags.KeyFile, "key-file", "", "Nomad: the key used with the certificate to verify the agent's authenticity. (see Nomad documentation for more info)")
-	command.Flags().BoolVar(&flags.EnableACL, "acl", false, "Nomad: enables Nomad ACL system. (see Nomad documentation for more info)")
-
-	command.RunE = func(command *coral.Command, args []string) error {
-		if !target.Local && len(target.Addr) == 0 {
-			return fmt.Errorf("required ssh-target-addr flag is missing")
-		}
-
-		ignoreConfigFlags := skipConfig || len(configFile) != 0
-
-		var generatedConfig string
-
-		if !ignoreConfigFlags {
-			generatedConfig = flags.GenerateConfigFile()
-		}
-
-		if len(binary) == 0 && len(version) == 0 {
-			latest, err := config.GetLatestVersion("nomad")
-
-			if err != nil {
-				return errors.Wrapf(err, "unable to get latest version number, define a version manually with the --version flag")
-			}
-
-			version = latest
-		}
-
-		callback := func(op operator.CommandOperator) error {
-			dir := "/tmp/hashi-up." + randstr.String(6)
-
-			defer op.Execute("rm -rf " + dir)
-
-			err := op.Execute("mkdir -p " + dir + "/config")
-			if err != nil {
-				return fmt.Errorf("error received during installation: %s", err)
-			}
-
-			if len(binary) != 0 {
-				info("Uploading Nomad package ...")
-				err = op.UploadFile(binary, dir+"/nomad.zip", "0640")
-				if err != nil {
-					return fmt.Errorf("error received during upload nomad package: %s", err)
-				}
-			}
-
-			if !skipConfig {
-				if !ignoreConfigFlags {
-					info("Uploading generated Nomad configuration ...")
-					err = op.Upload(strings.NewReader(generatedConfig), dir+"/config/nomad.hcl", "0640")
-					if err != nil {
-						return fmt.Errorf("error received during upload nomad configuration: %s", err)
-					}
-
-					files = []string{}
-
-					if flags.EnableTLS() {
-						files = []string{flags.CaFile, flags.KeyFile, flags.CertFile}
-					}
-				} else {
-					info(fmt.Sprintf("Uploading %s as nomad.hcl...", configFile))
-					err = op.UploadFile(expandPath(configFile), dir+"/config/nomad.hcl", "0640")
-					if err != nil {
-						return fmt.Errorf("error received during upload nomad configuration: %s", err)
-					}
-				}
-
-				for _, s := range files {
-					if len(s) != 0 {
-						info(fmt.Sprintf("Uploading %s...", s))
-						_, filename := filepath.Split(expandPath(s))
-						err = op.UploadFile(expandPath(s), dir+"/config/"+filename, "0640")
-						if err != nil {
-							return fmt.Errorf("error received during upload file: %s", err)
-						}
-					}
-				}
-			}
-
-			data := map[string]interface{}{
-				"TmpDir":     dir,
-				"SkipEnable": skipEnable,
-				"SkipStart":  skipStart,
-				"Version":    version,
-			}
-
-			installScript, err := scripts.RenderScript("install_nomad.sh", data)
-			if err != nil {
-				return err
-			}
-
-			err = op.Upload(installScript, dir+"/install.sh", "0755")
-			if err != nil {
-				return fmt.Errorf("error received during upload install script: %s", err)
-			}
-
-			info("Installing Nomad ...")
-			sudoPass, err := target.sudoPass()
-			if err != nil {
-				return fmt.Errorf("error received during installation: %s", err)
-			}
-			err = op.Execute(fmt.Sprintf("cat %s/install.sh | SUDO_PASS=\"%s\" sh -\n", dir, sudoPass))
-			if err != nil {
-				return fmt.Errorf("error received during installation: %s", err)
-			}
-
-			info("Done.")
-
-			return nil
-		}
-
-		return target.execute(callback)
+func (c *nomadConfigFlags) resolve() (*installer.Configuration, error) {
+	if err := c.prepare(); err != nil {
+		return nil, err
 	}
+	return installer.NomadConfiguration(c.flags, c.options())
+}
+
+func InstallNomadCommand() *coral.Command {
+	var flags = nomadConfigFlags{}
+
+	command := installCommand("nomad", &flags, func(ctx context.Context, target installer.Target, opts installer.Options) (*installer.Result, error) {
+		return installer.InstallNomad(ctx, target, flags.flags, opts)
+	})
+
+	flags.layout.prepareBinCommand(command, "nomad")
+	flags.layout.prepareUserCommand(command, "nomad")
 
 	return command
 }
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jsiebens/hashi-up/pkg/installer"
+	"github.com/muesli/coral"
+)
+
+type configFlags interface {
+	prepareCommand(command *coral.Command)
+	resolve() (*installer.Configuration, error)
+	prepare() error
+	options() installer.Options
+	configDir() string
+}
+
+// Renderer writes a product configuration to stdout or to a local directory instead of installing it on a target
+type Renderer struct {
+	Dest string
+}
+
+func (r *Renderer) prepareCommand(cmd *coral.Command) {
+	cmd.Flags().StringVarP(&r.Dest, "dest", "d", "", "Target directory for the rendered configuration file, when empty the configuration is written to stdout")
+}
+
+func (r *Renderer) render(c *installer.Configuration, configDir string) error {
+	if len(r.Dest) == 0 {
+		fmt.Print(c.Content)
+	} else {
+		if err := os.MkdirAll(expandPath(r.Dest), 0755); err != nil {
+			return err
+		}
+		target := filepath.Join(expandPath(r.Dest), c.Name)
+		if err := os.WriteFile(target, []byte(c.Content), 0640); err != nil {
+			return err
+		}
+		info(fmt.Sprintf("Wrote %s", target))
+	}
+
+	if len(configDir) != 0 {
+		// keep stdout clean so the rendered configuration can be redirected to a file
+		fmt.Fprintf(os.Stderr, "[INFO] %s would be installed as %s/%s\n", c.Name, configDir, c.Name)
+		for _, s := range c.Files {
+			if len(s) != 0 {
+				_, filename := filepath.Split(expandPath(s))
+				fmt.Fprintf(os.Stderr, "[INFO] %s would be uploaded to %s/%s\n", s, configDir, filename)
+			}
+		}
+	}
+
+	return nil
+}
+
+func ConfigCommand(product string, flags configFlags) *coral.Command {
+	var renderer = Renderer{}
+
+	var command = &coral.Command{
+		Use:          "config",
+		Short:        fmt.Sprintf("Render the %s configuration without installing it", strings.Title(product)),
+		Long:         fmt.Sprintf("Render the %s configuration, as generated by the install command with the same flags, without installing it", strings.Title(product)),
+		SilenceUsage: true,
+	}
+
+	renderer.prepareCommand(command)
+	flags.prepareCommand(command)
+
+	command.RunE = func(command *coral.Command, args []string) error {
+		c, err := flags.resolve()
+		if err != nil {
+			return err
+		}
+		return renderer.render(c, flags.configDir())
+	}
+
+	return command
+}
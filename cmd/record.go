@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jsiebens/hashi-up/pkg/config"
+	"github.com/jsiebens/hashi-up/pkg/installer"
+	"github.com/jsiebens/hashi-up/pkg/operator"
+	"github.com/muesli/coral"
+	"github.com/spf13/pflag"
+)
+
+// secretFlags are never written to the install record, so they have to be set again when the record is reused
+var secretFlags = map[string]bool{
+	"encrypt":         true,
+	"agent-token":     true,
+	"consul-token":    true,
+	"db-url":          true,
+	"recovery-key":    true,
+	"root-key":        true,
+	"worker-auth-key": true,
+	"env":             true,
+}
+
+// unrecordedFlags select the target, the configuration files of hashi-up or control the record itself, they are no parameters of the installation
+var unrecordedFlags = map[string]bool{
+	"help":           true,
+	"local":          true,
+	"reuse-previous": true,
+	"config":         true,
+	"profile":        true,
+}
+
+// unreusedFlags are recorded, but not reused: a reinstall installs the requested or latest version, never silently an older one
+var unreusedFlags = map[string]bool{
+	"version": true,
+	"package": true,
+}
+
+// Record keeps the parameters of an installation on the target, and reuses them for a next installation when requested
+type Record struct {
+	ReusePrevious bool
+}
+
+func (r *Record) prepareCommand(cmd *coral.Command, title string) {
+	cmd.Flags().BoolVar(&r.ReusePrevious, "reuse-previous", false, fmt.Sprintf("If set to true will reuse the flags of the previous %s installation recorded on the target, flags set on the command line take precedence", title))
+}
+
+// reuse loads the install record from the target and sets every recorded flag that is not set on the command line,
+// the recorded flags take precedence over the defaults of an environment variable or a configuration file
+func (r *Record) reuse(cmd *coral.Command, target *Target, product string) error {
+	if !r.ReusePrevious {
+		return nil
+	}
+
+	var record installer.Record
+
+	callback := func(op operator.CommandOperator) error {
+		cat, err := target.sudoCommand(fmt.Sprintf("cat %s 2>/dev/null || true", installer.RecordPath(product)))
+		if err != nil {
+			return err
+		}
+
+		res, err := op.ExecuteWithOutput(cat)
+		if err != nil {
+			return fmt.Errorf("error received while fetching %s: %s", installer.RecordPath(product), err)
+		}
+
+		if len(bytes.TrimSpace(res.StdOut)) == 0 {
+			return fmt.Errorf("no previous %s installation recorded in %s on %s", strings.Title(product), installer.RecordPath(product), target.name())
+		}
+
+		if err := json.Unmarshal(res.StdOut, &record); err != nil {
+			return fmt.Errorf("unable to read %s: %s", installer.RecordPath(product), err)
+		}
+
+		return nil
+	}
+
+	if err := target.execute(callback); err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range record.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// a flag recorded without its secret values, like --set, is not reused either, it has to be set again with all its values
+	recordedSecrets := map[string]bool{}
+	for _, name := range record.SecretFlags {
+		recordedSecrets[name] = true
+	}
+
+	var reused []string
+	for _, name := range names {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || (f.Changed && !isDefault(f)) || unreusedFlags[name] || secretFlags[name] || unrecordedFlags[name] || recordedSecrets[name] {
+			continue
+		}
+
+		var err error
+		switch value := record.Flags[name].(type) {
+		case string:
+			err = f.Value.Set(value)
+		case []interface{}:
+			s, ok := f.Value.(pflag.SliceValue)
+			if !ok {
+				err = fmt.Errorf("a single value is expected")
+				break
+			}
+			var values []string
+			for _, v := range value {
+				values = append(values, fmt.Sprint(v))
+			}
+			err = s.Replace(values)
+		default:
+			err = fmt.Errorf("unexpected value %v", value)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to reuse recorded flag %s: %s", name, err)
+		}
+		f.Changed = true
+		delete(f.Annotations, defaultAnnotation)
+		reused = append(reused, "--"+name)
+	}
+
+	var missing []string
+	for _, name := range record.SecretFlags {
+		if f := cmd.Flags().Lookup(name); f != nil && !f.Changed {
+			missing = append(missing, "--"+name)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("the previous installation used the secret flags %s, which are not recorded, set them again", strings.Join(missing, ", "))
+	}
+
+	info(fmt.Sprintf("Reusing the %s %s installation of %s: %s", strings.Title(product), record.Version, record.InstalledAt.Format(time.RFC3339), strings.Join(reused, " ")))
+
+	return nil
+}
+
+// record returns the flags of the current installation, to be written to the target by the installer
+func (r *Record) record(cmd *coral.Command) *installer.Record {
+	record := &installer.Record{
+		HashiUpVersion: Version,
+		Flags:          map[string]interface{}{},
+	}
+
+	// flags set from a reused record are only marked as changed, so they are not visited by Visit
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		switch {
+		case !f.Changed:
+		case unrecordedFlags[f.Name] || strings.HasPrefix(f.Name, "ssh-target-"):
+		case secretFlags[f.Name]:
+			record.SecretFlags = append(record.SecretFlags, f.Name)
+		case isDefault(f):
+			// a default of an environment variable or a configuration file is no parameter passed by the user
+		case f.Name == "set":
+			settings, secret := recordedSettings(f.Value.(pflag.SliceValue).GetSlice())
+			if len(settings) != 0 {
+				record.Flags[f.Name] = settings
+			}
+			if secret {
+				record.SecretFlags = append(record.SecretFlags, f.Name)
+			}
+		default:
+			if s, ok := f.Value.(pflag.SliceValue); ok {
+				record.Flags[f.Name] = s.GetSlice()
+			} else {
+				record.Flags[f.Name] = f.Value.String()
+			}
+		}
+	})
+
+	return record
+}
+
+// recordedSettings returns the settings without a secret, like a token set with --set acl.tokens.agent=...,
+// and whether a secret setting was left out
+func recordedSettings(values []string) ([]string, bool) {
+	var recorded []string
+	secret := false
+	for _, v := range values {
+		if s, err := config.ParseSetting(v); err == nil && s.Sensitive() {
+			secret = true
+			continue
+		}
+		recorded = append(recorded, v)
+	}
+	return recorded, secret
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jsiebens/hashi-up/pkg/operator"
+	"github.com/jsiebens/hashi-up/scripts"
+	"github.com/muesli/coral"
+	"github.com/thanhpk/randstr"
+)
+
+func RollbackCommand(product string) *coral.Command {
+
+	var command = &coral.Command{
+		Use:          "rollback",
+		Short:        fmt.Sprintf("Roll back %s to the previous release on a server via SSH", strings.Title(product)),
+		Long:         fmt.Sprintf("Roll back %s to the previously installed binary and configuration on a server via SSH and restart the service", strings.Title(product)),
+		SilenceUsage: true,
+	}
+
+	var target = Target{}
+	target.prepareCommand(command)
+
+	var initSystem = InitSystem{}
+	initSystem.prepareCommand(command)
+
+	var layout = Layout{}
+	layout.prepareConfigCommand(command, product)
+	layout.prepareBinCommand(command, product)
+
+	command.RunE = func(command *coral.Command, args []string) error {
+		if !target.Local && len(target.Addr) == 0 {
+			return fmt.Errorf("required ssh-target-addr flag is missing")
+		}
+
+		if err := initSystem.validate(); err != nil {
+			return err
+		}
+
+		if err := layout.validate(); err != nil {
+			return err
+		}
+
+		callback := func(op operator.CommandOperator) error {
+			dir := "/tmp/hashi-up." + randstr.String(6)
+
+			defer op.Execute("rm -rf " + dir)
+
+			err := op.Execute("mkdir -p " + dir)
+			if err != nil {
+				return fmt.Errorf("error received during rollback: %s", err)
+			}
+
+			data := map[string]interface{}{
+				"InitSystem": initSystem.Name,
+			}
+
+			rollbackScript, err := scripts.RenderScript("rollback.sh", data)
+			if err != nil {
+				return err
+			}
+
+			err = op.Upload(rollbackScript, dir+"/run.sh", "0755")
+			if err != nil {
+				return fmt.Errorf("error received during upload rollback script: %s", err)
+			}
+
+			info(fmt.Sprintf("Rolling back %s ...", strings.Title(product)))
+			sudoPass, err := target.sudoPass()
+			if err != nil {
+				return fmt.Errorf("error received during rollback: %s", err)
+			}
+			err = op.Execute(fmt.Sprintf("cat %s/run.sh | SERVICE=%s %s SUDO_PASS=\"%s\" sh -\n", dir, product, layout.env(), sudoPass))
+			if err != nil {
+				return fmt.Errorf("error received during rollback: %s", err)
+			}
+
+			info("Done.")
+
+			return nil
+		}
+
+		return target.execute(callback)
+	}
+
+	return command
+}
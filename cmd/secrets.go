@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/muesli/coral"
+)
+
+// secretReferenceFlags accept a reference to a secret instead of the secret itself,
+// keeping the secret out of the shell history and CI logs
+var secretReferenceFlags = []string{
+	"encrypt",
+	"agent-token",
+	"consul-token",
+	"db-url",
+	"recovery-key",
+	"root-key",
+	"worker-auth-key",
+	"ssh-target-password",
+	"ssh-target-sudo-pass",
+}
+
+// pathFlags also accept the path of a file holding the password instead of a reference, as they did before references were supported
+var pathFlags = map[string]bool{
+	"ssh-target-password":  true,
+	"ssh-target-sudo-pass": true,
+}
+
+// trustedReferences run code or reach out to a server, they are only resolved when typed on the command line,
+// never from a configuration file, e.g. the .hashi-up.yaml of an untrusted checkout, or an environment variable
+var trustedReferences = []string{"cmd:", "vault:"}
+
+// resolveSecretFlags replaces every secret reference set on the secret flags of a command by the secret it refers to,
+// the flags hold the secret itself afterwards so they are never resolved again
+func resolveSecretFlags(cmd *coral.Command) error {
+	for _, name := range secretReferenceFlags {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || !f.Changed {
+			continue
+		}
+
+		if isDefault(f) {
+			for _, prefix := range trustedReferences {
+				if strings.HasPrefix(f.Value.String(), prefix) {
+					return fmt.Errorf("the %s reference of %s is only resolved on the command line, not from a configuration file or environment variable", prefix, name)
+				}
+			}
+		}
+
+		resolve := resolveSecret
+		if pathFlags[name] {
+			resolve = resolveSecretOrPath
+		}
+
+		value, err := resolve(f.Value.String())
+		if err != nil {
+			return fmt.Errorf("unable to resolve the secret reference of %s: %s", name, err)
+		}
+
+		if err := f.Value.Set(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveSecret returns the secret a reference refers to: the content of a file with @path, an environment variable
+// with env:NAME, the output of a command with cmd:command or a field of a Vault secret with vault:path#field,
+// any other value is the secret itself; a leading backslash escapes a secret looking like a reference, e.g. \@secret is @secret
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `\`):
+		return strings.TrimPrefix(value, `\`), nil
+	case strings.HasPrefix(value, "@"):
+		content, err := os.ReadFile(expandPath(strings.TrimPrefix(value, "@")))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(content)), nil
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, "cmd:"):
+		return commandSecret(strings.TrimPrefix(value, "cmd:"))
+	case strings.HasPrefix(value, "vault:"):
+		return vaultSecret(strings.TrimPrefix(value, "vault:"))
+	default:
+		return value, nil
+	}
+}
+
+// resolveSecretOrPath resolves a secret reference, or reads the password from a file when the value is the path of an existing file
+func resolveSecretOrPath(value string) (string, error) {
+	if isSecretReference(value) {
+		return resolveSecret(value)
+	}
+	return pathOrContents(value)
+}
+
+// isSecretReference reports whether a value is a reference or an escaped secret, resolved by resolveSecret
+func isSecretReference(value string) bool {
+	for _, prefix := range []string{`\`, "@", "env:", "cmd:", "vault:"} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandSecret runs a command, like the CLI of a password manager, and returns its output
+func commandSecret(command string) (string, error) {
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.Command("cmd", "/C", command)
+	} else {
+		c = exec.Command("sh", "-c", command)
+	}
+	c.Stdin = os.Stdin
+	c.Stderr = os.Stderr
+
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("command '%s' failed: %s", command, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// vaultSecret reads a field of a secret from Vault, configured with the same environment variables as the vault CLI;
+// the path of a secret in a KV version 2 engine includes data/, e.g. secret/data/consul#gossip
+func vaultSecret(reference string) (string, error) {
+	path, field, found := strings.Cut(reference, "#")
+	if !found || len(path) == 0 || len(field) == 0 {
+		return "", fmt.Errorf("invalid vault reference '%s', expected format is vault:path#field", reference)
+	}
+
+	addr := os.Getenv("VAULT_ADDR")
+	if len(addr) == 0 {
+		addr = "https://127.0.0.1:8200"
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if len(token) == 0 {
+		content, err := os.ReadFile(expandPath("~/.vault-token"))
+		if err != nil {
+			return "", fmt.Errorf("no Vault token found in VAULT_TOKEN or ~/.vault-token")
+		}
+		token = strings.TrimSpace(string(content))
+	}
+
+	client, err := vaultClient()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); len(namespace) != 0 {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to read %s from Vault, invalid response code %d", path, res.StatusCode)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("unable to read %s from Vault: %s", path, err)
+	}
+
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %s not found in %s", field, path)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	content, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func vaultClient() (*http.Client, error) {
+	config := &tls.Config{}
+
+	if ca := os.Getenv("VAULT_CACERT"); len(ca) != 0 {
+		content, err := os.ReadFile(expandPath(ca))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read VAULT_CACERT: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no certificates found in VAULT_CACERT %s", ca)
+		}
+		config.RootCAs = pool
+	}
+
+	if skip, _ := strconv.ParseBool(os.Getenv("VAULT_SKIP_VERIFY")); skip {
+		config.InsecureSkipVerify = true
+	}
+
+	return &http.Client{
+		Timeout:   time.Second * 10,
+		Transport: &http.Transport{TLSClientConfig: config, Proxy: http.ProxyFromEnvironment},
+	}, nil
+}
@@ -14,19 +14,42 @@ func ManageServiceCommand(action, product string) *coral.Command {
 
 	var command = &coral.Command{
 		Use:          action,
-		Short:        fmt.Sprintf("%s %s systemd service on a server via SSH", strings.Title(action), strings.Title(product)),
-		Long:         fmt.Sprintf("%s %s systemd service on a server via SSH", strings.Title(action), strings.Title(product)),
+		Short:        fmt.Sprintf("%s %s service on a server via SSH", strings.Title(action), strings.Title(product)),
+		Long:         fmt.Sprintf("%s %s service on a server via SSH", strings.Title(action), strings.Title(product)),
 		SilenceUsage: true,
 	}
 
 	var target = Target{}
 	target.prepareCommand(command)
 
+	var initSystem = InitSystem{}
+	initSystem.prepareCommand(command)
+
+	var layout = Layout{}
+	var graceful = GracefulStop{}
+	if action == "stop" {
+		layout.prepareConfigCommand(command, product)
+		layout.prepareBinCommand(command, product)
+		graceful.prepareCommand(command, product)
+	}
+
 	command.RunE = func(command *coral.Command, args []string) error {
 		if !target.Local && len(target.Addr) == 0 {
 			return fmt.Errorf("required ssh-target-addr flag is missing")
 		}
 
+		if err := initSystem.validate(); err != nil {
+			return err
+		}
+
+		if err := layout.validate(); err != nil {
+			return err
+		}
+
+		if err := graceful.validate(); err != nil {
+			return err
+		}
+
 		callback := func(op operator.CommandOperator) error {
 			dir := "/tmp/hashi-up." + randstr.String(6)
 
@@ -37,14 +60,15 @@ func ManageServiceCommand(action, product string) *coral.Command {
 				return fmt.Errorf("error received during preparation: %s", err)
 			}
 
-			installScript, err := scripts.Open("service.sh")
+			data := map[string]interface{}{
+				"InitSystem": initSystem.Name,
+			}
 
+			installScript, err := scripts.RenderScript("service.sh", graceful.apply(data))
 			if err != nil {
 				return err
 			}
 
-			defer installScript.Close()
-
 			err = op.Upload(installScript, dir+"/run.sh", "0755")
 			if err != nil {
 				return fmt.Errorf("error received during upload install script: %s", err)
@@ -55,7 +79,7 @@ func ManageServiceCommand(action, product string) *coral.Command {
 			if err != nil {
 				return fmt.Errorf("error received during execution: %s", err)
 			}
-			err = op.Execute(fmt.Sprintf("cat %s/run.sh | ACTION=%s SERVICE=%s SUDO_PASS=\"%s\" sh -\n", dir, action, product, sudoPass))
+			err = op.Execute(fmt.Sprintf("cat %s/run.sh | ACTION=%s SERVICE=%s %s SUDO_PASS=\"%s\" sh -\n", dir, action, product, layout.env(), sudoPass))
 			if err != nil {
 				return fmt.Errorf("error received during execution: %s", err)
 			}
@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jsiebens/hashi-up/pkg/installer"
+	"github.com/jsiebens/hashi-up/pkg/operator"
+	"github.com/jsiebens/hashi-up/scripts"
+	"github.com/muesli/coral"
+	"github.com/thanhpk/randstr"
+)
+
+func SnapshotCommand(product string) *coral.Command {
+	command := baseCommand("snapshot")
+	command.Short = fmt.Sprintf("Save or restore a snapshot of the %s state on a server via SSH", strings.Title(product))
+	command.Long = fmt.Sprintf("Save or restore a snapshot of the %s state on a server via SSH", strings.Title(product))
+	command.AddCommand(snapshotSaveCommand(product))
+	command.AddCommand(snapshotRestoreCommand(product))
+	return command
+}
+
+func snapshotSaveCommand(product string) *coral.Command {
+
+	var dest string
+
+	var command = &coral.Command{
+		Use:          "save",
+		Short:        fmt.Sprintf("Save a snapshot of the %s state on a server and download it via SSH", strings.Title(product)),
+		Long:         fmt.Sprintf("Save a snapshot of the %s state on a server and download it via SSH, next to a file with its SHA-256 checksum", strings.Title(product)),
+		SilenceUsage: true,
+	}
+
+	var target = Target{}
+	target.prepareCommand(command)
+
+	var layout = Layout{}
+	layout.prepareConfigCommand(command, product)
+	layout.prepareBinCommand(command, product)
+
+	command.Flags().StringVarP(&dest, "dest", "d", ".", "Local directory to download the snapshot to")
+
+	command.RunE = func(command *coral.Command, args []string) error {
+		if !target.Local && len(target.Addr) == 0 {
+			return fmt.Errorf("required ssh-target-addr flag is missing")
+		}
+
+		if err := layout.validate(); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(expandPath(dest), 0755); err != nil {
+			return err
+		}
+
+		name := fmt.Sprintf("%s-%s.snap", product, time.Now().UTC().Format("20060102T150405Z"))
+		local := filepath.Join(expandPath(dest), name)
+
+		callback := func(op operator.CommandOperator) error {
+			dir := "/tmp/hashi-up." + randstr.String(6)
+
+			defer op.Execute("rm -rf " + dir)
+
+			err := op.Execute("mkdir -p " + dir)
+			if err != nil {
+				return fmt.Errorf("error received during preparation: %s", err)
+			}
+
+			if err := runSnapshotScript(op, &target, layout, product, dir, "save"); err != nil {
+				return err
+			}
+
+			remoteHash, err := remoteFileHash(op, dir+"/"+product+".snap")
+			if err != nil {
+				return err
+			}
+
+			file, err := os.Create(local)
+			if err != nil {
+				return fmt.Errorf("unable to create snapshot %s: %s", local, err)
+			}
+
+			info(fmt.Sprintf("Downloading snapshot to %s ...", local))
+			err = op.ExecuteWithWriter(fmt.Sprintf("cat %s/%s.snap", dir, product), file, os.Stderr)
+			if cerr := file.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(local)
+				return fmt.Errorf("error received during download snapshot: %s", err)
+			}
+
+			localHash, err := installer.FileHash(local)
+			if err != nil {
+				return err
+			}
+
+			if localHash != remoteHash {
+				os.Remove(local)
+				return fmt.Errorf("checksum mismatch for downloaded snapshot, expected %s but got %s", remoteHash, localHash)
+			}
+
+			if err := os.WriteFile(local+".sha256", []byte(fmt.Sprintf("%s  %s\n", localHash, name)), 0644); err != nil {
+				return err
+			}
+
+			info(fmt.Sprintf("Saved snapshot %s, sha256 %s", local, localHash))
+
+			return nil
+		}
+
+		return target.execute(callback)
+	}
+
+	return command
+}
+
+func snapshotRestoreCommand(product string) *coral.Command {
+
+	var yes bool
+
+	var command = &coral.Command{
+		Use:          "restore <snapshot>",
+		Short:        fmt.Sprintf("Upload a snapshot and restore the %s state on a server via SSH", strings.Title(product)),
+		Long:         fmt.Sprintf("Upload a snapshot and restore the %s state on a server via SSH, verifying the snapshot against its SHA-256 checksum file when available", strings.Title(product)),
+		Args:         coral.ExactArgs(1),
+		SilenceUsage: true,
+	}
+
+	var target = Target{}
+	target.prepareCommand(command)
+
+	var layout = Layout{}
+	layout.prepareConfigCommand(command, product)
+	layout.prepareBinCommand(command, product)
+
+	command.Flags().BoolVarP(&yes, "yes", "y", false, "If set to true will not ask for confirmation")
+
+	command.RunE = func(command *coral.Command, args []string) error {
+		if !target.Local && len(target.Addr) == 0 {
+			return fmt.Errorf("required ssh-target-addr flag is missing")
+		}
+
+		if err := layout.validate(); err != nil {
+			return err
+		}
+
+		local := expandPath(args[0])
+
+		localHash, err := installer.FileHash(local)
+		if err != nil {
+			return err
+		}
+
+		if err := verifyChecksumFile(local, localHash); err != nil {
+			return err
+		}
+
+		if !yes {
+			ok, err := confirm(fmt.Sprintf("Restore %s on %s, replacing its current state?", args[0], target.name()))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("restore of %s snapshot aborted", strings.Title(product))
+			}
+		}
+
+		callback := func(op operator.CommandOperator) error {
+			dir := "/tmp/hashi-up." + randstr.String(6)
+
+			defer op.Execute("rm -rf " + dir)
+
+			err := op.Execute("mkdir -p " + dir)
+			if err != nil {
+				return fmt.Errorf("error received during preparation: %s", err)
+			}
+
+			info(fmt.Sprintf("Uploading snapshot %s ...", args[0]))
+			err = op.UploadFile(local, dir+"/"+product+".snap", "0600")
+			if err != nil {
+				return fmt.Errorf("error received during upload snapshot: %s", err)
+			}
+
+			remoteHash, err := remoteFileHash(op, dir+"/"+product+".snap")
+			if err != nil {
+				return err
+			}
+
+			if remoteHash != localHash {
+				return fmt.Errorf("checksum mismatch for uploaded snapshot, expected %s but got %s", localHash, remoteHash)
+			}
+
+			return runSnapshotScript(op, &target, layout, product, dir, "restore")
+		}
+
+		return target.execute(callback)
+	}
+
+	return command
+}
+
+func runSnapshotScript(op operator.CommandOperator, target *Target, layout Layout, product string, dir string, action string) error {
+	snapshotScript, err := scripts.RenderScript("snapshot.sh", map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+
+	err = op.Upload(snapshotScript, dir+"/run.sh", "0755")
+	if err != nil {
+		return fmt.Errorf("error received during upload snapshot script: %s", err)
+	}
+
+	info(fmt.Sprintf("Running %s snapshot %s ...", strings.Title(product), action))
+	sudoPass, err := target.sudoPass()
+	if err != nil {
+		return fmt.Errorf("error received during snapshot %s: %s", action, err)
+	}
+	err = op.Execute(fmt.Sprintf("cat %s/run.sh | ACTION=%s SERVICE=%s SNAPSHOT=%s/%s.snap %s SUDO_PASS=\"%s\" sh -\n", dir, action, product, dir, product, layout.env(), sudoPass))
+	if err != nil {
+		return fmt.Errorf("error received during snapshot %s: %s", action, err)
+	}
+
+	return nil
+}
+
+func remoteFileHash(op operator.CommandOperator, path string) (string, error) {
+	res, err := op.ExecuteWithOutput("sha256sum " + path)
+	if err != nil {
+		return "", fmt.Errorf("error received while hashing %s: %s", path, err)
+	}
+
+	fields := strings.Fields(string(res.StdOut))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unable to hash %s", path)
+	}
+
+	return fields[0], nil
+}
+
+// verifyChecksumFile verifies a local file against the checksum file written next to it by snapshot save, if any
+func verifyChecksumFile(path string, hash string) error {
+	content, err := os.ReadFile(path + ".sha256")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 || fields[0] != hash {
+		return fmt.Errorf("checksum mismatch for %s, the snapshot doesn't match %s.sha256", path, path)
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/jsiebens/hashi-up/pkg/config"
+	"github.com/jsiebens/hashi-up/pkg/operator"
+	"github.com/jsiebens/hashi-up/scripts"
+	"github.com/muesli/coral"
+	"github.com/thanhpk/randstr"
+)
+
+// productStatus is the state of a product installation on a single target, as reported by the status script
+type productStatus struct {
+	Target          string             `json:"target"`
+	Installed       bool               `json:"installed"`
+	Version         string             `json:"version,omitempty"`
+	LatestVersion   string             `json:"latest_version,omitempty"`
+	UpdateAvailable bool               `json:"update_available"`
+	InitSystem      string             `json:"init_system,omitempty"`
+	State           string             `json:"state,omitempty"`
+	Enabled         bool               `json:"enabled"`
+	Uptime          int64              `json:"uptime_seconds,omitempty"`
+	ConfigFiles     []configFileStatus `json:"config_files,omitempty"`
+	Error           string             `json:"error,omitempty"`
+}
+
+type configFileStatus struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+func StatusCommand(product string) *coral.Command {
+
+	var output string
+
+	var command = &coral.Command{
+		Use:          "status",
+		Short:        fmt.Sprintf("Show the status of %s on one or more servers via SSH", strings.Title(product)),
+		Long:         fmt.Sprintf("Show the installed version, the service state and the configuration file hashes of %s on one or more servers via SSH", strings.Title(product)),
+		SilenceUsage: true,
+	}
+
+	var targets = Targets{}
+	targets.prepareCommand(command)
+
+	var initSystem = InitSystem{}
+	initSystem.prepareCommand(command)
+
+	var layout = Layout{}
+	layout.prepareConfigCommand(command, product)
+	layout.prepareBinCommand(command, product)
+
+	command.Flags().StringVarP(&output, "output", "o", "text", "Output format, text or json")
+
+	command.RunE = func(command *coral.Command, args []string) error {
+		if err := targets.validate(); err != nil {
+			return err
+		}
+
+		if output != "text" && output != "json" {
+			return fmt.Errorf("invalid output '%s', supported are text and json", output)
+		}
+
+		if err := initSystem.validate(); err != nil {
+			return err
+		}
+
+		if err := layout.validate(); err != nil {
+			return err
+		}
+
+		// the latest version is only informative, so a failing lookup doesn't fail the command
+		latest, _ := config.GetLatestVersion(product)
+
+		var statuses []productStatus
+		failed := false
+
+		for _, target := range targets.each() {
+			status := productStatus{Target: target.name()}
+
+			callback := func(op operator.CommandOperator) error {
+				return fetchStatus(op, &target, &status, product, initSystem, layout)
+			}
+
+			if err := target.execute(callback); err != nil {
+				failed = true
+				status.Error = err.Error()
+			}
+
+			if status.Installed && len(latest) != 0 {
+				status.LatestVersion = latest
+				status.UpdateAvailable = newerVersion(status.Version, latest)
+			}
+
+			statuses = append(statuses, status)
+		}
+
+		if output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(statuses); err != nil {
+				return err
+			}
+		} else {
+			for _, s := range statuses {
+				printStatus(s)
+			}
+		}
+
+		if failed {
+			return fmt.Errorf("unable to get the status of %s on all targets", strings.Title(product))
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+func fetchStatus(op operator.CommandOperator, target *Target, status *productStatus, product string, initSystem InitSystem, layout Layout) error {
+	dir := "/tmp/hashi-up." + randstr.String(6)
+
+	defer op.Execute("rm -rf " + dir)
+
+	err := op.Execute("mkdir -p " + dir)
+	if err != nil {
+		return fmt.Errorf("error received during preparation: %s", err)
+	}
+
+	data := map[string]interface{}{
+		"InitSystem": initSystem.Name,
+	}
+
+	statusScript, err := scripts.RenderScript("status.sh", data)
+	if err != nil {
+		return err
+	}
+
+	err = op.Upload(statusScript, dir+"/run.sh", "0755")
+	if err != nil {
+		return fmt.Errorf("error received during upload status script: %s", err)
+	}
+
+	sudoPass, err := target.sudoPass()
+	if err != nil {
+		return fmt.Errorf("error received during execution: %s", err)
+	}
+	res, err := op.ExecuteWithOutput(fmt.Sprintf("cat %s/run.sh | SERVICE=%s %s SUDO_PASS=\"%s\" sh -\n", dir, product, layout.env(), sudoPass))
+	if err != nil {
+		return fmt.Errorf("error received during execution: %s", err)
+	}
+
+	parseStatus(res.StdOut, status)
+
+	return nil
+}
+
+// parseStatus reads the key=value lines printed by the status script, ignoring any other output
+func parseStatus(out []byte, status *productStatus) {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "installed":
+			status.Installed = value == "true"
+		case "version":
+			status.Version = value
+		case "init_system":
+			status.InitSystem = value
+		case "state":
+			status.State = value
+		case "enabled":
+			status.Enabled = value == "true"
+		case "uptime":
+			status.Uptime, _ = strconv.ParseInt(value, 10, 64)
+		case "config":
+			if hash, path, found := strings.Cut(value, " "); found {
+				status.ConfigFiles = append(status.ConfigFiles, configFileStatus{Path: path, SHA256: hash})
+			}
+		}
+	}
+}
+
+func newerVersion(installed, latest string) bool {
+	i, err := semver.NewVersion(installed)
+	if err != nil {
+		return false
+	}
+	l, err := semver.NewVersion(latest)
+	if err != nil {
+		return false
+	}
+	return l.GreaterThan(i)
+}
+
+func printStatus(s productStatus) {
+	fmt.Println(s.Target)
+
+	if len(s.Error) != 0 {
+		fmt.Printf("  error:    %s\n", s.Error)
+		return
+	}
+
+	if !s.Installed {
+		fmt.Println("  version:  not installed")
+	} else if s.UpdateAvailable {
+		fmt.Printf("  version:  %s (%s available)\n", s.Version, s.LatestVersion)
+	} else {
+		fmt.Printf("  version:  %s\n", s.Version)
+	}
+
+	if len(s.State) == 0 {
+		fmt.Printf("  service:  not installed (%s)\n", s.InitSystem)
+	} else {
+		enabled := "disabled"
+		if s.Enabled {
+			enabled = "enabled"
+		}
+		fmt.Printf("  service:  %s, %s (%s)\n", s.State, enabled, s.InitSystem)
+	}
+
+	if s.Uptime != 0 {
+		fmt.Printf("  uptime:   %s\n", time.Duration(s.Uptime)*time.Second)
+	}
+
+	for _, c := range s.ConfigFiles {
+		fmt.Printf("  config:   %s sha256 %s\n", c.Path, c.SHA256)
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jsiebens/hashi-up/pkg/installer"
+	"github.com/muesli/coral"
+)
+
+// ServiceOptions customizes the generated systemd unit of a product, either directly or with drop-in files
+// written to /etc/systemd/system/<product>.service.d
+type ServiceOptions struct {
+	Args     []string
+	Limits   []string
+	After    []string
+	Requires []string
+	DropIns  []string
+	Hardened bool
+}
+
+func (s *ServiceOptions) prepareCommand(cmd *coral.Command, title string) {
+	cmd.Flags().StringArrayVar(&s.Args, "service-arg", []string{}, fmt.Sprintf("Extra argument to append to the ExecStart command of the %s service. Can be specified multiple times.", title))
+	cmd.Flags().StringArrayVar(&s.Limits, "service-limit", []string{}, fmt.Sprintf("Resource limit for the %s service, in the form NAME=VALUE, e.g. NOFILE=65536 or MEMLOCK=infinity. Can be specified multiple times.", title))
+	cmd.Flags().StringArrayVar(&s.After, "service-after", []string{}, fmt.Sprintf("Unit to start before the %s service, added as After= dependency. Can be specified multiple times.", title))
+	cmd.Flags().StringArrayVar(&s.Requires, "service-requires", []string{}, fmt.Sprintf("Unit required by the %s service, added as Requires= dependency. Can be specified multiple times.", title))
+	cmd.Flags().StringArrayVar(&s.DropIns, "service-dropin", []string{}, fmt.Sprintf("Local systemd drop-in file to upload to the %s service drop-in directory. Can be specified multiple times.", title))
+	cmd.Flags().BoolVar(&s.Hardened, "service-hardened", false, fmt.Sprintf("If set to true will run the %s service with a hardened profile (ProtectSystem, PrivateTmp, NoNewPrivileges)", title))
+}
+
+// dropIn returns the content of the drop-in file managed by hashi-up, or an empty string when no customization is required
+func (s *ServiceOptions) dropIn() (string, error) {
+	var unit []string
+	var service []string
+
+	for _, a := range s.After {
+		unit = append(unit, "After="+a)
+	}
+	for _, r := range s.Requires {
+		unit = append(unit, "Requires="+r)
+	}
+
+	for _, l := range s.Limits {
+		name, value, found := strings.Cut(l, "=")
+		if !found || len(name) == 0 || len(value) == 0 {
+			return "", fmt.Errorf("invalid service limit '%s', expected format is NAME=VALUE", l)
+		}
+		name = strings.ToUpper(strings.TrimPrefix(name, "Limit"))
+		service = append(service, fmt.Sprintf("Limit%s=%s", name, value))
+	}
+
+	if s.Hardened {
+		service = append(service,
+			"ProtectSystem=full",
+			"ProtectHome=read-only",
+			"PrivateTmp=yes",
+			"NoNewPrivileges=yes",
+		)
+	}
+
+	if len(unit) == 0 && len(service) == 0 {
+		return "", nil
+	}
+
+	content := "# generated with hashi-up\n"
+	if len(unit) != 0 {
+		content = content + "\n[Unit]\n" + strings.Join(unit, "\n") + "\n"
+	}
+	if len(service) != 0 {
+		content = content + "\n[Service]\n" + strings.Join(service, "\n") + "\n"
+	}
+
+	return content, nil
+}
+
+// service returns the customization of the systemd unit for the installer
+func (s *ServiceOptions) service() (installer.Service, error) {
+	dropIn, err := s.dropIn()
+	if err != nil {
+		return installer.Service{}, err
+	}
+	return installer.Service{Args: s.Args, DropIn: dropIn, DropInFiles: s.DropIns}, nil
+}
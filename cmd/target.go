@@ -1,18 +1,17 @@
 package cmd
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
 
+	"github.com/jsiebens/hashi-up/pkg/installer"
 	"github.com/jsiebens/hashi-up/pkg/operator"
 	"github.com/mitchellh/go-homedir"
 	"github.com/muesli/coral"
 )
 
-const SshTargetPassword = "SSH_TARGET_PASSWORD"
-const SshTargetSudoPass = "SSH_TARGET_SUDO_PASS"
-
 type Target struct {
 	Addr     string
 	User     string
@@ -24,6 +23,10 @@ type Target struct {
 
 func (t *Target) prepareCommand(cmd *coral.Command) {
 	cmd.Flags().StringVarP(&t.Addr, "ssh-target-addr", "r", "", "Remote SSH target address (e.g. 127.0.0.1:22")
+	t.prepareCredentialsCommand(cmd)
+}
+
+func (t *Target) prepareCredentialsCommand(cmd *coral.Command) {
 	cmd.Flags().StringVarP(&t.User, "ssh-target-user", "u", "root", "Username for SSH login")
 	cmd.Flags().StringVarP(&t.Key, "ssh-target-key", "k", "", "The ssh key to use for SSH login")
 	cmd.Flags().StringVarP(&t.Password, "ssh-target-password", "p", "", "The ssh password to use for SSH login")
@@ -31,35 +34,78 @@ func (t *Target) prepareCommand(cmd *coral.Command) {
 	cmd.Flags().BoolVar(&t.Local, "local", false, "Running the installation locally, without ssh")
 }
 
+// name identifies the target in the output of commands operating on several targets
+func (t *Target) name() string {
+	if t.Local {
+		return "localhost"
+	}
+	return t.Addr
+}
+
 func (t *Target) execute(callback operator.Callback) error {
 	if t.Local {
 		return operator.ExecuteLocal(callback)
 	} else {
-		pwd, err := pathOrContents(getenv(SshTargetPassword, t.Password))
-		if err != nil {
-			return err
-		}
-		return operator.ExecuteRemote(t.Addr, t.User, t.Key, pwd, callback)
+		return operator.ExecuteRemote(t.Addr, t.User, t.Key, t.Password, callback)
 	}
 }
 
+// installerTarget returns the target for the installer, the passwords are already resolved with the other secret flags
+func (t *Target) installerTarget() (installer.Target, error) {
+	return installer.Target{Addr: t.Addr, User: t.User, Key: t.Key, Password: t.Password, SudoPass: t.SudoPass, Local: t.Local}, nil
+}
+
+// Targets are one or more SSH targets sharing the same credentials, for commands operating on several servers at once
+type Targets struct {
+	Target
+	Addrs []string
+}
+
+func (t *Targets) prepareCommand(cmd *coral.Command) {
+	cmd.Flags().StringSliceVarP(&t.Addrs, "ssh-target-addr", "r", []string{}, "Remote SSH target addresses (e.g. 127.0.0.1:22), comma separated or specified multiple times")
+	t.prepareCredentialsCommand(cmd)
+}
+
+func (t *Targets) validate() error {
+	if !t.Local && len(t.Addrs) == 0 {
+		return fmt.Errorf("required ssh-target-addr flag is missing")
+	}
+	return nil
+}
+
+// each returns a target for every address, or only the local target when running locally
+func (t *Targets) each() []Target {
+	if t.Local {
+		return []Target{t.Target}
+	}
+	var targets []Target
+	for _, addr := range t.Addrs {
+		target := t.Target
+		target.Addr = addr
+		targets = append(targets, target)
+	}
+	return targets
+}
+
 func (t *Target) sudoPass() (string, error) {
-	sudoPass := getenv(SshTargetSudoPass, t.SudoPass)
-	if len(sudoPass) != 0 {
-		pwd, err := pathOrContents(sudoPass)
-		if err != nil {
-			return "", err
-		} else {
-			return pwd, nil
-		}
-	} else {
-		pwd, err := pathOrContents(getenv(SshTargetPassword, t.Password))
-		if err != nil {
-			return "", err
-		} else {
-			return pwd, nil
-		}
+	if len(t.SudoPass) != 0 {
+		return t.SudoPass, nil
 	}
+	return t.Password, nil
+}
+
+// sudoCommand wraps a command so it runs with root privileges on the target, using the sudo password when required
+func (t *Target) sudoCommand(command string) (string, error) {
+	sudoPass, err := t.sudoPass()
+	if err != nil {
+		return "", err
+	}
+	inner := "sh -c " + shellQuote(command)
+	return fmt.Sprintf("if [ \"$(id -u)\" -eq 0 ]; then %s; else echo %s | sudo -S -p '' %s; fi", inner, shellQuote(sudoPass), inner), nil
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
 }
 
 func pathOrContents(poc string) (string, error) {
@@ -86,14 +132,3 @@ func pathOrContents(poc string) (string, error) {
 
 	return poc, nil
 }
-
-func getenv(key, override string) string {
-	if len(override) != 0 {
-		return override
-	}
-	value := os.Getenv(key)
-	if len(value) != 0 {
-		return value
-	}
-	return ""
-}
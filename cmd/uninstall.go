@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/jsiebens/hashi-up/pkg/operator"
@@ -12,6 +14,11 @@ import (
 
 func UninstallCommand(product string) *coral.Command {
 
+	var keepData bool
+	var keepConfig bool
+	var backup string
+	var yes bool
+
 	var command = &coral.Command{
 		Use:          "uninstall",
 		Short:        fmt.Sprintf("Uninstall %s on a server via SSH", strings.Title(product)),
@@ -22,11 +29,48 @@ func UninstallCommand(product string) *coral.Command {
 	var target = Target{}
 	target.prepareCommand(command)
 
+	var initSystem = InitSystem{}
+	initSystem.prepareCommand(command)
+
+	var layout = Layout{}
+	layout.prepareCommand(command, product)
+	layout.prepareBinCommand(command, product)
+
+	var graceful = GracefulStop{}
+	graceful.prepareCommand(command, product)
+
+	command.Flags().BoolVar(&keepData, "keep-data", false, fmt.Sprintf("If set to true will keep the %s data directory", strings.Title(product)))
+	command.Flags().BoolVar(&keepConfig, "keep-config", false, fmt.Sprintf("If set to true will keep the %s configuration directory", strings.Title(product)))
+	command.Flags().StringVar(&backup, "backup", "", "Local path of a tarball to download the configuration and data directories to before removing them")
+	command.Flags().BoolVarP(&yes, "yes", "y", false, "If set to true will not ask for confirmation")
+
 	command.RunE = func(command *coral.Command, args []string) error {
 		if !target.Local && len(target.Addr) == 0 {
 			return fmt.Errorf("required ssh-target-addr flag is missing")
 		}
 
+		if err := initSystem.validate(); err != nil {
+			return err
+		}
+
+		if err := layout.validate(); err != nil {
+			return err
+		}
+
+		if err := graceful.validate(); err != nil {
+			return err
+		}
+
+		if !yes {
+			ok, err := confirm(uninstallQuestion(product, target.name(), layout, keepData, keepConfig))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("uninstall of %s aborted", strings.Title(product))
+			}
+		}
+
 		callback := func(op operator.CommandOperator) error {
 			dir := "/tmp/hashi-up." + randstr.String(6)
 
@@ -37,14 +81,23 @@ func UninstallCommand(product string) *coral.Command {
 				return fmt.Errorf("error received during installation: %s", err)
 			}
 
-			installScript, err := scripts.Open("uninstall.sh")
+			if len(backup) != 0 {
+				if err := downloadBackup(op, &target, []string{layout.ConfigDir, layout.DataDir}, backup); err != nil {
+					return err
+				}
+			}
+
+			data := map[string]interface{}{
+				"InitSystem": initSystem.Name,
+				"KeepData":   keepData,
+				"KeepConfig": keepConfig,
+			}
 
+			installScript, err := scripts.RenderScript("uninstall.sh", graceful.apply(data))
 			if err != nil {
 				return err
 			}
 
-			defer installScript.Close()
-
 			err = op.Upload(installScript, dir+"/run.sh", "0755")
 			if err != nil {
 				return fmt.Errorf("error received during upload install script: %s", err)
@@ -55,7 +108,7 @@ func UninstallCommand(product string) *coral.Command {
 			if err != nil {
 				return fmt.Errorf("error received during installation: %s", err)
 			}
-			err = op.Execute(fmt.Sprintf("cat %s/run.sh | SERVICE=%s SUDO_PASS=\"%s\" sh -\n", dir, product, sudoPass))
+			err = op.Execute(fmt.Sprintf("cat %s/run.sh | SERVICE=%s %s SUDO_PASS=\"%s\" sh -\n", dir, product, layout.env(), sudoPass))
 			if err != nil {
 				return fmt.Errorf("error received during uninstallation: %s", err)
 			}
@@ -70,3 +123,63 @@ func UninstallCommand(product string) *coral.Command {
 
 	return command
 }
+
+func uninstallQuestion(product string, target string, layout Layout, keepData bool, keepConfig bool) string {
+	removed := []string{"the service", "the binary"}
+	if !keepConfig {
+		removed = append(removed, layout.ConfigDir)
+	}
+	if !keepData {
+		removed = append(removed, layout.DataDir)
+	}
+	return fmt.Sprintf("Uninstall %s from %s, removing %s?", strings.Title(product), target, strings.Join(removed, ", "))
+}
+
+// confirm asks a yes or no question on the terminal, anything but yes is an answer of no
+func confirm(question string) (bool, error) {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && len(answer) == 0 {
+		fmt.Println()
+		return false, nil
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// downloadBackup streams a tarball of the existing directories on the target to a local file
+func downloadBackup(op operator.CommandOperator, target *Target, dirs []string, dest string) error {
+	var paths []string
+	for _, d := range dirs {
+		paths = append(paths, shellQuote(strings.TrimPrefix(d, "/")))
+	}
+
+	tar, err := target.sudoCommand(fmt.Sprintf("cd / && set -- && for d in %s; do [ -e \"$d\" ] && set -- \"$@\" \"$d\"; done; [ $# -eq 0 ] || tar czf - \"$@\"", strings.Join(paths, " ")))
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(expandPath(dest))
+	if err != nil {
+		return fmt.Errorf("unable to create backup %s: %s", dest, err)
+	}
+
+	info(fmt.Sprintf("Downloading backup to %s ...", dest))
+	err = op.ExecuteWithWriter(tar, file, os.Stderr)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(expandPath(dest))
+		return fmt.Errorf("error received during backup: %s", err)
+	}
+
+	if stat, err := os.Stat(expandPath(dest)); err == nil && stat.Size() == 0 {
+		info("Nothing to back up")
+		return os.Remove(expandPath(dest))
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/jsiebens/hashi-up/pkg/config"
+	"github.com/jsiebens/hashi-up/pkg/operator"
+	"github.com/jsiebens/hashi-up/scripts"
+	"github.com/muesli/coral"
+	"github.com/pkg/errors"
+	"github.com/thanhpk/randstr"
+)
+
+func UpgradeCommand(product string) *coral.Command {
+
+	var version string
+	var allowDowngrade bool
+	var skipDependencies bool
+
+	var command = &coral.Command{
+		Use:          "upgrade",
+		Short:        fmt.Sprintf("Upgrade the %s binary on one or more servers via SSH, one server at a time", strings.Title(product)),
+		Long:         fmt.Sprintf("Upgrade the %s binary on one or more servers via SSH, one server at a time with the leader last, waiting for the cluster to become healthy before upgrading the next server", strings.Title(product)),
+		SilenceUsage: true,
+	}
+
+	var targets = Targets{}
+	targets.prepareCommand(command)
+
+	var initSystem = InitSystem{}
+	initSystem.prepareCommand(command)
+
+	var health = HealthCheck{}
+	health.prepareCommand(command, fmt.Sprintf("the %s cluster", strings.Title(product)))
+
+	var layout = Layout{}
+	layout.prepareConfigCommand(command, product)
+	layout.prepareBinCommand(command, product)
+
+	command.Flags().StringVarP(&version, "version", "v", "", fmt.Sprintf("Version of %s to upgrade to, defaults to the latest version", strings.Title(product)))
+	command.Flags().BoolVar(&allowDowngrade, "allow-downgrade", false, fmt.Sprintf("If set to true will install the version even when it is older than the installed %s version", strings.Title(product)))
+	command.Flags().BoolVar(&skipDependencies, "skip-dependencies", false, "If set to true will not install missing dependencies, like curl and unzip, on the target")
+
+	command.RunE = func(command *coral.Command, args []string) error {
+		if err := targets.validate(); err != nil {
+			return err
+		}
+
+		if err := initSystem.validate(); err != nil {
+			return err
+		}
+
+		if err := health.validate(); err != nil {
+			return err
+		}
+
+		if err := layout.validate(); err != nil {
+			return err
+		}
+
+		if len(version) == 0 {
+			latest, err := config.GetLatestVersion(product)
+
+			if err != nil {
+				return errors.Wrapf(err, "unable to get latest version number, define a version manually with the --version flag")
+			}
+
+			version = latest
+		}
+
+		requested, err := semver.NewVersion(version)
+		if err != nil {
+			return fmt.Errorf("invalid version '%s': %s", version, err)
+		}
+
+		data := map[string]interface{}{
+			"InitSystem":       initSystem.Name,
+			"SkipDependencies": skipDependencies,
+			"Version":          version,
+			"ArmSuffix":        config.GetArmSuffix(product, version),
+		}
+
+		health.apply(data, config.HealthEndpoint{})
+
+		// every server is inspected before the first one is upgraded, so a refused downgrade leaves all servers untouched
+		var followers, leaders []Target
+		var refused []string
+		for _, target := range targets.each() {
+			var inspection upgradeInspection
+
+			callback := func(op operator.CommandOperator) error {
+				res, err := runUpgradeScript(op, &target, product, "inspect", layout, data)
+				if err != nil {
+					return err
+				}
+				inspection = parseUpgradeInspection(res)
+				return nil
+			}
+
+			if err := target.execute(callback); err != nil {
+				return fmt.Errorf("unable to inspect %s: %s", target.name(), err)
+			}
+
+			installed, err := semver.NewVersion(inspection.version)
+			switch {
+			case err != nil && !allowDowngrade:
+				refused = append(refused, fmt.Sprintf("%s (unknown version '%s')", target.name(), inspection.version))
+				continue
+			case err == nil && installed.Equal(requested):
+				info(fmt.Sprintf("%s %s is already installed on %s, skipping", strings.Title(product), installed, target.name()))
+				continue
+			case err == nil && installed.GreaterThan(requested) && !allowDowngrade:
+				refused = append(refused, fmt.Sprintf("%s (%s)", target.name(), installed))
+				continue
+			}
+
+			// the leader is upgraded last, so leadership changes only once during the upgrade
+			if inspection.leader {
+				leaders = append(leaders, target)
+			} else {
+				followers = append(followers, target)
+			}
+		}
+
+		if len(refused) != 0 {
+			return fmt.Errorf("refusing to downgrade %s to %s on %s, use --allow-downgrade to downgrade anyway", strings.Title(product), version, strings.Join(refused, ", "))
+		}
+
+		order := append(followers, leaders...)
+
+		if len(order) == 0 {
+			info("Done.")
+			return nil
+		}
+
+		var names []string
+		for _, target := range order {
+			names = append(names, target.name())
+		}
+		for i := range leaders {
+			names[len(followers)+i] += " (leader)"
+		}
+		info(fmt.Sprintf("Upgrading %s to %s on %s", strings.Title(product), version, strings.Join(names, ", ")))
+
+		for i, target := range order {
+			info(fmt.Sprintf("Upgrading %s on %s (%d/%d) ...", strings.Title(product), target.name(), i+1, len(order)))
+
+			callback := func(op operator.CommandOperator) error {
+				_, err := runUpgradeScript(op, &target, product, "upgrade", layout, data)
+				return err
+			}
+
+			if err := target.execute(callback); err != nil {
+				return fmt.Errorf("upgrade halted on %s, %d of %d servers upgraded: %s", target.name(), i, len(order), err)
+			}
+		}
+
+		info("Done.")
+
+		return nil
+	}
+
+	return command
+}
+
+// runUpgradeScript runs the given action of the upgrade script, the output is only captured to inspect a server
+func runUpgradeScript(op operator.CommandOperator, target *Target, product string, action string, layout Layout, data map[string]interface{}) ([]byte, error) {
+	dir := "/tmp/hashi-up." + randstr.String(6)
+
+	defer op.Execute("rm -rf " + dir)
+
+	err := op.Execute("mkdir -p " + dir)
+	if err != nil {
+		return nil, fmt.Errorf("error received during preparation: %s", err)
+	}
+
+	data["TmpDir"] = dir
+
+	upgradeScript, err := scripts.RenderScript("upgrade.sh", data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = op.Upload(upgradeScript, dir+"/run.sh", "0755")
+	if err != nil {
+		return nil, fmt.Errorf("error received during upload upgrade script: %s", err)
+	}
+
+	sudoPass, err := target.sudoPass()
+	if err != nil {
+		return nil, fmt.Errorf("error received during upgrade: %s", err)
+	}
+
+	command := fmt.Sprintf("cat %s/run.sh | ACTION=%s SERVICE=%s %s SUDO_PASS=\"%s\" sh -\n", dir, action, product, layout.env(), sudoPass)
+
+	if action == "inspect" {
+		res, err := op.ExecuteWithOutput(command)
+		if err != nil {
+			os.Stdout.Write(res.StdOut)
+			return nil, fmt.Errorf("error received during upgrade: %s", err)
+		}
+		return res.StdOut, nil
+	}
+
+	err = op.Execute(command)
+	if err != nil {
+		return nil, fmt.Errorf("error received during upgrade: %s", err)
+	}
+
+	return nil, nil
+}
+
+// upgradeInspection is the installed version and the raft role of a server, as reported by the upgrade script
+type upgradeInspection struct {
+	version string
+	leader  bool
+}
+
+// parseUpgradeInspection reads the key=value lines printed by the upgrade script, ignoring any other output
+func parseUpgradeInspection(out []byte) upgradeInspection {
+	var inspection upgradeInspection
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "version":
+			inspection.version = value
+		case "role":
+			inspection.leader = value == "leader"
+		}
+	}
+	return inspection
+}
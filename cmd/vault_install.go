@@ -1,175 +1,102 @@
 package cmd
 
 import (
-	"fmt"
-	"path/filepath"
-	"strings"
+	"context"
 
 	"github.com/jsiebens/hashi-up/pkg/config"
-	"github.com/jsiebens/hashi-up/pkg/operator"
-	"github.com/jsiebens/hashi-up/scripts"
+	"github.com/jsiebens/hashi-up/pkg/installer"
 	"github.com/muesli/coral"
-	"github.com/pkg/errors"
-	"github.com/thanhpk/randstr"
 )
 
-func InstallVaultCommand() *coral.Command {
+func VaultConfigCommand() *coral.Command {
+	return ConfigCommand("vault", &vaultConfigFlags{})
+}
+
+func VaultDiffCommand() *coral.Command {
+	return DiffCommand("vault", &vaultConfigFlags{})
+}
+
+func VaultSnapshotCommand() *coral.Command {
+	return SnapshotCommand("vault")
+}
+
+func VaultBackupScheduleCommand() *coral.Command {
+	return BackupScheduleCommand("vault")
+}
+
+type vaultConfigFlags struct {
+	configFile string
+	files      []string
+	settings   []string
+	format     string
+	flags      config.VaultConfig
+	layout     Layout
+}
+
+func (c *vaultConfigFlags) prepareCommand(command *coral.Command) {
+	command.Flags().StringVarP(&c.configFile, "config-file", "c", "", "Custom Vault configuration file to upload, setting this will disable config file generation meaning the other flags are ignored")
+	command.Flags().StringSliceVarP(&c.files, "file", "f", []string{}, "Additional files, e.g. certificates, to upload")
+	command.Flags().StringArrayVar(&c.settings, "set", []string{}, "Set an arbitrary Vault configuration attribute in the generated configuration file, e.g. log_level=debug or telemetry.disable_hostname=true. Can be specified multiple times.")
+	command.Flags().StringVar(&c.format, "config-format", config.FormatHCL, "Format of the generated Vault configuration file, hcl or json, a config-file keeps its own format")
+	c.layout.prepareCommand(command, "vault")
+
+	command.Flags().StringVar(&c.flags.CertFile, "cert-file", "", "Vault: the certificate for TLS. (see Vault documentation for more info)")
+	command.Flags().StringVar(&c.flags.KeyFile, "key-file", "", "Vault: the private key for the certificate. (see Vault documentation for more info)")
+	command.Flags().StringSliceVar(&c.flags.Address, "address", []string{"0.0.0.0:8200"}, "Vault: the address to bind to for listening. (see Vault documentation for more info)")
+	command.Flags().StringVar(&c.flags.ApiAddr, "api-addr", "", "Vault: the address (full URL) to advertise to other Vault servers in the cluster for client redirection. (see Vault documentation for more info)")
+	command.Flags().StringVar(&c.flags.ClusterAddr, "cluster-addr", "", "Vault: the address to advertise to other Vault servers in the cluster for request forwarding. (see Vault documentation for more info)")
+	command.Flags().StringVar(&c.flags.Storage, "storage", "file", "Vault: the type of storage backend. Currently only \"file\" of \"consul\" is supported. (see Vault documentation for more info)")
+	command.Flags().StringVar(&c.flags.ConsulAddr, "consul-addr", "127.0.0.1:8500", "Vault: the address of the Consul agent to communicate with. (see Vault documentation for more info)")
+	command.Flags().StringVar(&c.flags.ConsulPath, "consul-path", "vault/", "Vault: the path in Consul's key-value store where Vault data will be stored. (see Vault documentation for more info)")
+	command.Flags().StringVar(&c.flags.ConsulToken, "consul-token", "", "Vault: the Consul ACL token with permission to read and write from the path in Consul's key-value store. (see Vault documentation for more info)")
+	command.Flags().StringVar(&c.flags.ConsulCaFile, "consul-tls-ca-file", "", "Vault: the path to the CA certificate used for Consul communication. (see Vault documentation for more info)")
+	command.Flags().StringVar(&c.flags.ConsulCertFile, "consul-tls-cert-file", "", "Vault: the path to the certificate for Consul communication. (see Vault documentation for more info)")
+	command.Flags().StringVar(&c.flags.ConsulKeyFile, "consul-tls-key-file", "", "Vault: the path to the private key for Consul communication. (see Vault documentation for more info)")
+}
 
-	var skipConfig bool
-	var skipEnable bool
-	var skipStart bool
-	var binary string
-	var version string
+func (c *vaultConfigFlags) configDir() string {
+	return c.layout.ConfigDir
+}
+
+func (c *vaultConfigFlags) options() installer.Options {
+	return installer.Options{ConfigFile: c.configFile, Files: c.files, ConfigFormat: c.format, Layout: c.layout.Layout}
+}
 
-	var configFile string
-	var files []string
+// prepare validates the layout and parses the settings of the generated configuration
+func (c *vaultConfigFlags) prepare() error {
+	if err := c.layout.validate(); err != nil {
+		return err
+	}
 
-	var flags = config.VaultConfig{}
+	if len(c.configFile) != 0 {
+		return nil
+	}
 
-	var command = &coral.Command{
-		Use:          "install",
-		Short:        "Install Vault on a server via SSH",
-		Long:         "Install Vault on a server via SSH",
-		SilenceUsage: true,
+	settings, err := config.ParseSettings(c.settings)
+	if err != nil {
+		return err
 	}
+	c.flags.Settings = settings
+
+	return nil
+}
 
-	var target = Target{}
-	target.prepareCommand(command)
-
-	command.Flags().BoolVar(&skipConfig, "skip-config", false, "If set to true will install Vault service without touching existing config files")
-	command.Flags().BoolVar(&skipEnable, "skip-enable", false, "If set to true will not enable or start Vault service")
-	command.Flags().BoolVar(&skipStart, "skip-start", false, "If set to true will not start Vault service")
-	command.Flags().StringVar(&binary, "package", "", "Upload and use this Vault package instead of downloading")
-	command.Flags().StringVarP(&version, "version", "v", "", "Version of Vault to install")
-
-	command.Flags().StringVarP(&configFile, "config-file", "c", "", "Custom Vault configuration file to upload, setting this will disable config file generation meaning the other flags are ignored")
-	command.Flags().StringSliceVarP(&files, "file", "f", []string{}, "Additional files, e.g. certificates, to upload")
-
-	command.Flags().StringVar(&flags.CertFile, "cert-file", "", "Vault: the certificate for TLS. (see Vault documentation for more info)")
-	command.Flags().StringVar(&flags.KeyFile, "key-file", "", "Vault: the private key for the certificate. (see Vault documentation for more info)")
-	command.Flags().StringSliceVar(&flags.Address, "address", []string{"0.0.0.0:8200"}, "Vault: the address to bind to for listening. (see Vault documentation for more info)")
-	command.Flags().StringVar(&flags.ApiAddr, "api-addr", "", "Vault: the address (full URL) to advertise to other Vault servers in the cluster for client redirection. (see Vault documentation for more info)")
-	command.Flags().StringVar(&flags.ClusterAddr, "cluster-addr", "", "Vault: the address to advertise to other Vault servers in the cluster for request forwarding. (see Vault documentation for more info)")
-	command.Flags().StringVar(&flags.Storage, "storage", "file", "Vault: the type of storage backend. Currently only \"file\" of \"consul\" is supported. (see Vault documentation for more info)")
-	command.Flags().StringVar(&flags.ConsulAddr, "consul-addr", "127.0.0.1:8500", "Vault: the address of the Consul agent to communicate with. (see Vault documentation for more info)")
-	command.Flags().StringVar(&flags.ConsulPath, "consul-path", "vault/", "Vault: the path in Consul's key-value store where Vault data will be stored. (see Vault documentation for more info)")
-	command.Flags().StringVar(&flags.ConsulToken, "consul-token", "", "Vault: the Consul ACL token with permission to read and write from the path in Consul's key-value store. (see Vault documentation for more info)")
-	command.Flags().StringVar(&flags.ConsulCaFile, "consul-tls-ca-file", "", "Vault: the path to the CA certificate used for Consul communication. (see Vault documentation for more info)")
-	command.Flags().StringVar(&flags.ConsulCertFile, "consul-tls-cert-file", "", "Vault: the path to the certificate for Consul communication. (see Vault documentation for more info)")
-	command.Flags().StringVar(&flags.ConsulKeyFile, "consul-tls-key-file", "", "Vault: the path to the private key for Consul communication. (see Vault documentation for more info)")
-
-	command.RunE = func(command *coral.Command, args []string) error {
-		if !target.Local && len(target.Addr) == 0 {
-			return fmt.Errorf("required ssh-target-addr flag is missing")
-		}
-
-		ignoreConfigFlags := skipConfig || len(configFile) != 0
-
-		var generatedConfig string
-
-		if !ignoreConfigFlags {
-			generatedConfig = flags.GenerateConfigFile()
-		}
-
-		if len(binary) == 0 && len(version) == 0 {
-			latest, err := config.GetLatestVersion("vault")
-
-			if err != nil {
-				return errors.Wrapf(err, "unable to get latest version number, define a version manually with the --version flag")
-			}
-
-			version = latest
-		}
-
-		callback := func(op operator.CommandOperator) error {
-			dir := "/tmp/hashi-up." + randstr.String(6)
-
-			defer op.Execute("rm -rf " + dir)
-
-			err := op.Execute("mkdir -p " + dir + "/config")
-			if err != nil {
-				return fmt.Errorf("error received during installation: %s", err)
-			}
-
-			if len(binary) != 0 {
-				info("Uploading Vault package ...")
-				err = op.UploadFile(binary, dir+"/vault.zip", "0644")
-				if err != nil {
-					return fmt.Errorf("error received during upload Vault package: %s", err)
-				}
-			}
-
-			if !skipConfig {
-				if !ignoreConfigFlags {
-					info("Uploading generated Vault configuration ...")
-					err = op.Upload(strings.NewReader(generatedConfig), dir+"/config/vault.hcl", "0640")
-					if err != nil {
-						return fmt.Errorf("error received during upload consul configuration: %s", err)
-					}
-
-					files = []string{}
-
-					if flags.EnableTLS() {
-						files = append(files, flags.KeyFile, flags.CertFile)
-					}
-
-					if flags.EnableConsulTLS() {
-						files = append(files, flags.ConsulCaFile, flags.ConsulCertFile, flags.ConsulKeyFile)
-					}
-				} else {
-					info(fmt.Sprintf("Uploading %s as vault.hcl...", configFile))
-					err = op.UploadFile(expandPath(configFile), dir+"/config/vault.hcl", "0640")
-					if err != nil {
-						return fmt.Errorf("error received during upload nomad configuration: %s", err)
-					}
-				}
-
-				for _, s := range files {
-					if len(s) != 0 {
-						info(fmt.Sprintf("Uploading %s...", s))
-						_, filename := filepath.Split(expandPath(s))
-						err = op.UploadFile(expandPath(s), dir+"/config/"+filename, "0640")
-						if err != nil {
-							return fmt.Errorf("error received during upload file: %s", err)
-						}
-					}
-				}
-			}
-
-			data := map[string]interface{}{
-				"TmpDir":     dir,
-				"SkipEnable": skipEnable,
-				"SkipStart":  skipStart,
-				"Version":    version,
-			}
-
-			installScript, err := scripts.RenderScript("install_vault.sh", data)
-			if err != nil {
-				return err
-			}
-
-			err = op.Upload(installScript, dir+"/install.sh", "0755")
-			if err != nil {
-				return fmt.Errorf("error received during upload install script: %s", err)
-			}
-
-			info("Installing Vault ...")
-			sudoPass, err := target.sudoPass()
-			if err != nil {
-				return fmt.Errorf("error received during installation: %s", err)
-			}
-			err = op.Execute(fmt.Sprintf("cat %s/install.sh | SUDO_PASS=\"%s\" sh -\n", dir, sudoPass))
-			if err != nil {
-				return fmt.Errorf("error received during installation: %s", err)
-			}
-
-			info("Done.")
-
-			return nil
-		}
-
-		return target.execute(callback)
+func (c *vaultConfigFlags) resolve() (*installer.Configuration, error) {
+	if err := c.prepare(); err != nil {
+		return nil, err
 	}
+	return installer.VaultConfiguration(c.flags, c.options())
+}
+
+func InstallVaultCommand() *coral.Command {
+	var flags = vaultConfigFlags{}
+
+	command := installCommand("vault", &flags, func(ctx context.Context, target installer.Target, opts installer.Options) (*installer.Result, error) {
+		return installer.InstallVault(ctx, target, flags.flags, opts)
+	})
+
+	flags.layout.prepareBinCommand(command, "vault")
+	flags.layout.prepareUserCommand(command, "vault")
 
 	return command
 }
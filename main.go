@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -11,11 +12,19 @@ import (
 func main() {
 	if err := cmd.Execute(); err != nil {
 
-		switch err.(type) {
-		case *operator.TargetConnectError:
-			fmt.Printf(targetConnectErrorMessage, err)
-		case *operator.SshAgentError:
-			fmt.Printf(sshAgentErrorMessage, err)
+		// errors of the installer wrap the errors of the operator
+		var connectErr *operator.TargetConnectError
+		var agentErr *operator.SshAgentError
+		var driftErr *cmd.DriftError
+
+		switch {
+		case errors.As(err, &driftErr):
+			fmt.Println(err)
+			os.Exit(2)
+		case errors.As(err, &connectErr):
+			fmt.Printf(targetConnectErrorMessage, connectErr)
+		case errors.As(err, &agentErr):
+			fmt.Printf(sshAgentErrorMessage, agentErr)
 		default:
 			fmt.Println(err)
 		}
@@ -81,3 +81,38 @@ func Unzip(source, destination string) error {
 
 	return nil
 }
+
+// Open opens a single file of a zip archive, closing the returned reader closes the archive as well
+func Open(source, name string) (io.ReadCloser, error) {
+	r, err := zip.OpenReader(source)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range r.File {
+		if f.Name == name {
+			rc, err := f.Open()
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			return &fileReader{ReadCloser: rc, archive: r}, nil
+		}
+	}
+
+	r.Close()
+	return nil, fmt.Errorf("unable to find %s in %s", name, source)
+}
+
+type fileReader struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (f *fileReader) Close() error {
+	err := f.ReadCloser.Close()
+	if err := f.archive.Close(); err != nil {
+		return err
+	}
+	return err
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -23,7 +26,10 @@ type BoundaryConfig struct {
 	ProxyCertFile        string
 	PublicAddress        string
 	PublicClusterAddress string
+	OpsAddress           string
 	Controllers          []string
+	ConfigDir            string
+	Settings             []Setting
 }
 
 func (c *BoundaryConfig) HasDatabaseURL() bool {
@@ -74,6 +80,48 @@ func (c *BoundaryConfig) ClusterTLSEnabled() bool {
 	return len(c.ClusterCertFile) != 0 && len(c.ClusterKeyFile) != 0
 }
 
+func (c *BoundaryConfig) configDir() string {
+	return valueOrDefault(c.ConfigDir, "/etc/boundary.d")
+}
+
+// ValidateBoundaryConfig reports the syntax errors of a custom Boundary configuration file, and a configuration
+// without a controller or worker block or without a listener, as Boundary has no command to validate a configuration on the target
+func ValidateBoundaryConfig(name, content, format string) error {
+	file, err := parseFile(name, content, format)
+	if err != nil {
+		return err
+	}
+
+	body, _, diags := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "controller"},
+			{Type: "worker"},
+			{Type: "listener", LabelNames: []string{"type"}},
+		},
+	})
+	if diags.HasErrors() {
+		return diags
+	}
+
+	blocks := body.Blocks.ByType()
+	if len(blocks["controller"]) == 0 && len(blocks["worker"]) == 0 {
+		return fmt.Errorf("a controller or worker block is required")
+	}
+	if len(blocks["listener"]) == 0 {
+		return fmt.Errorf("at least one listener block is required")
+	}
+	return nil
+}
+
+// HealthEndpoint returns the health endpoint of the ops listener, Boundary has no health endpoint without that listener
+// or when the settings change the listeners
+func (c *BoundaryConfig) HealthEndpoint() HealthEndpoint {
+	if len(c.OpsAddress) == 0 || overridesListener(c.Settings, "listener") {
+		return HealthEndpoint{}
+	}
+	return HealthEndpoint{URL: healthURL("http", c.OpsAddress, "9203", "/health")}
+}
+
 func (c *BoundaryConfig) GenerateDbConfigFile() string {
 	f := hclwrite.NewEmptyFile()
 	rootBody := f.Body()
@@ -93,7 +141,7 @@ func (c *BoundaryConfig) GenerateDbConfigFile() string {
 	return generate(f)
 }
 
-func (c *BoundaryConfig) GenerateConfigFile() string {
+func (c *BoundaryConfig) GenerateConfigFile() (string, error) {
 	f := hclwrite.NewEmptyFile()
 	rootBody := f.Body()
 
@@ -126,8 +174,8 @@ func (c *BoundaryConfig) GenerateConfigFile() string {
 
 		if c.ApiTLSEnabled() {
 			apiAddressBlock.Body().SetAttributeValue("tls_disable", cty.BoolVal(false))
-			apiAddressBlock.Body().SetAttributeValue("tls_cert_file", cty.StringVal(makeAbsolute(c.ApiCertFile, "/etc/boundary.d")))
-			apiAddressBlock.Body().SetAttributeValue("tls_key_file", cty.StringVal(makeAbsolute(c.ApiKeyFile, "/etc/boundary.d")))
+			apiAddressBlock.Body().SetAttributeValue("tls_cert_file", cty.StringVal(makeAbsolute(c.ApiCertFile, c.configDir())))
+			apiAddressBlock.Body().SetAttributeValue("tls_key_file", cty.StringVal(makeAbsolute(c.ApiKeyFile, c.configDir())))
 		} else {
 			apiAddressBlock.Body().SetAttributeValue("tls_disable", cty.BoolVal(true))
 		}
@@ -141,8 +189,8 @@ func (c *BoundaryConfig) GenerateConfigFile() string {
 
 		if c.ClusterTLSEnabled() {
 			clusterAddressBlock.Body().SetAttributeValue("tls_disable", cty.BoolVal(false))
-			clusterAddressBlock.Body().SetAttributeValue("tls_cert_file", cty.StringVal(makeAbsolute(c.ClusterCertFile, "/etc/boundary.d")))
-			clusterAddressBlock.Body().SetAttributeValue("tls_key_file", cty.StringVal(makeAbsolute(c.ClusterKeyFile, "/etc/boundary.d")))
+			clusterAddressBlock.Body().SetAttributeValue("tls_cert_file", cty.StringVal(makeAbsolute(c.ClusterCertFile, c.configDir())))
+			clusterAddressBlock.Body().SetAttributeValue("tls_key_file", cty.StringVal(makeAbsolute(c.ClusterKeyFile, c.configDir())))
 		} else {
 			clusterAddressBlock.Body().SetAttributeValue("tls_disable", cty.BoolVal(true))
 		}
@@ -155,13 +203,20 @@ func (c *BoundaryConfig) GenerateConfigFile() string {
 
 		if c.ProxyTLSEnabled() {
 			proxyAddressBlock.Body().SetAttributeValue("tls_disable", cty.BoolVal(false))
-			proxyAddressBlock.Body().SetAttributeValue("tls_cert_file", cty.StringVal(makeAbsolute(c.ProxyCertFile, "/etc/boundary.d")))
-			proxyAddressBlock.Body().SetAttributeValue("tls_key_file", cty.StringVal(makeAbsolute(c.ProxyKeyFile, "/etc/boundary.d")))
+			proxyAddressBlock.Body().SetAttributeValue("tls_cert_file", cty.StringVal(makeAbsolute(c.ProxyCertFile, c.configDir())))
+			proxyAddressBlock.Body().SetAttributeValue("tls_key_file", cty.StringVal(makeAbsolute(c.ProxyKeyFile, c.configDir())))
 		} else {
 			proxyAddressBlock.Body().SetAttributeValue("tls_disable", cty.BoolVal(true))
 		}
 	}
 
+	if len(c.OpsAddress) != 0 {
+		opsAddressBlock := rootBody.AppendNewBlock("listener", []string{"tcp"})
+		opsAddressBlock.Body().SetAttributeValue("purpose", cty.StringVal("ops"))
+		opsAddressBlock.Body().SetAttributeValue("address", cty.StringVal(c.OpsAddress))
+		opsAddressBlock.Body().SetAttributeValue("tls_disable", cty.BoolVal(true))
+	}
+
 	if len(c.RootKey) != 0 {
 		rootKeyBlock := rootBody.AppendNewBlock("kms", []string{"aead"})
 		rootKeyBlock.Body().SetAttributeValue("purpose", cty.StringVal("root"))
@@ -186,7 +241,11 @@ func (c *BoundaryConfig) GenerateConfigFile() string {
 		recoveryKeyBlock.Body().SetAttributeValue("key_id", cty.StringVal("global_recovery"))
 	}
 
-	return generate(f)
+	if err := applySettings(rootBody, c.Settings); err != nil {
+		return "", err
+	}
+
+	return generate(f), nil
 }
 
 func allOrNothing(a, b string) bool {
@@ -26,19 +26,22 @@ type ConsulConfig struct {
 	AgentToken      string
 	EnableConnect   bool
 	HttpsOnly       bool
+	DataDir         string
+	ConfigDir       string
+	Settings        []Setting
 }
 
 func (c ConsulConfig) EnableTLS() bool {
 	return c.AutoEncrypt || (len(c.CaFile) != 0 && len(c.CertFile) != 0 && len(c.KeyFile) != 0)
 }
 
-func (c ConsulConfig) GenerateConfigFile() string {
+func (c ConsulConfig) GenerateConfigFile() (string, error) {
 
 	f := hclwrite.NewEmptyFile()
 	rootBody := f.Body()
 
 	rootBody.SetAttributeValue("datacenter", cty.StringVal(c.Datacenter))
-	rootBody.SetAttributeValue("data_dir", cty.StringVal("/opt/consul"))
+	rootBody.SetAttributeValue("data_dir", cty.StringVal(c.dataDir()))
 
 	if len(c.BindAddr) != 0 {
 		rootBody.SetAttributeValue("bind_addr", cty.StringVal(c.BindAddr))
@@ -96,11 +99,11 @@ func (c ConsulConfig) GenerateConfigFile() string {
 	}
 
 	if c.EnableTLS() {
-		rootBody.SetAttributeValue("ca_file", cty.StringVal(makeAbsolute(c.CaFile, "/etc/consul.d")))
+		rootBody.SetAttributeValue("ca_file", cty.StringVal(makeAbsolute(c.CaFile, c.configDir())))
 
 		if c.Server || !c.AutoEncrypt {
-			rootBody.SetAttributeValue("cert_file", cty.StringVal(makeAbsolute(c.CertFile, "/etc/consul.d")))
-			rootBody.SetAttributeValue("key_file", cty.StringVal(makeAbsolute(c.KeyFile, "/etc/consul.d")))
+			rootBody.SetAttributeValue("cert_file", cty.StringVal(makeAbsolute(c.CertFile, c.configDir())))
+			rootBody.SetAttributeValue("key_file", cty.StringVal(makeAbsolute(c.KeyFile, c.configDir())))
 		}
 
 		rootBody.SetAttributeValue("verify_incoming_rpc", cty.BoolVal(true))
@@ -137,5 +140,38 @@ func (c ConsulConfig) GenerateConfigFile() string {
 		connectBlock.Body().SetAttributeValue("enabled", cty.BoolVal(true))
 	}
 
-	return generate(f)
+	if err := applySettings(rootBody, c.Settings); err != nil {
+		return "", err
+	}
+
+	return generate(f), nil
+}
+
+// HealthEndpoint returns the leader status endpoint of the HTTP API, or of the HTTPS API when the HTTP port is disabled,
+// an agent without a leader reports an empty leader; no endpoint is returned when the settings change the listeners
+func (c ConsulConfig) HealthEndpoint() HealthEndpoint {
+	if overridesListener(c.Settings, "client_addr", "addresses", "ports", "tls", "ca_file", "cert_file", "key_file", "verify_incoming", "verify_incoming_https") {
+		return HealthEndpoint{}
+	}
+	if c.EnableTLS() && c.HttpsOnly {
+		h := HealthEndpoint{
+			URL:       healthURL("https", valueOrDefault(c.HttpsAddr, c.ClientAddr), "8501", "/v1/status/leader"),
+			CaFile:    makeAbsolute(c.CaFile, c.configDir()),
+			Unhealthy: `""`,
+		}
+		if len(c.CertFile) != 0 && len(c.KeyFile) != 0 {
+			h.CertFile = makeAbsolute(c.CertFile, c.configDir())
+			h.KeyFile = makeAbsolute(c.KeyFile, c.configDir())
+		}
+		return h
+	}
+	return HealthEndpoint{URL: healthURL("http", valueOrDefault(c.HttpAddr, c.ClientAddr), "8500", "/v1/status/leader"), Unhealthy: `""`}
+}
+
+func (c ConsulConfig) dataDir() string {
+	return valueOrDefault(c.DataDir, "/opt/consul")
+}
+
+func (c ConsulConfig) configDir() string {
+	return valueOrDefault(c.ConfigDir, "/etc/consul.d")
 }
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+const (
+	FormatHCL  = "hcl"
+	FormatJSON = "json"
+)
+
+func ValidateFormat(format string) error {
+	if format != FormatHCL && format != FormatJSON {
+		return fmt.Errorf("invalid config format '%s', supported formats are %s and %s", format, FormatHCL, FormatJSON)
+	}
+	return nil
+}
+
+// FileName returns the name of the main configuration file of a product in the given format, e.g. consul.json
+func FileName(product, format string) string {
+	return product + "." + format
+}
+
+// FileFormat returns the format of a configuration file from its extension, json for a .json file and hcl otherwise
+func FileFormat(path string) string {
+	if strings.EqualFold(filepath.Ext(path), "."+FormatJSON) {
+		return FormatJSON
+	}
+	return FormatHCL
+}
+
+// parseFile parses a configuration file in the given format, reporting its syntax errors
+func parseFile(name, content, format string) (*hcl.File, error) {
+	parser := hclparse.NewParser()
+
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if format == FormatJSON {
+		file, diags = parser.ParseJSON([]byte(content), name)
+	} else {
+		file, diags = parser.ParseHCL([]byte(content), name)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return file, nil
+}
+
+// ConvertFormat converts a generated HCL configuration file to the given format
+func ConvertFormat(content string, format string) (string, error) {
+	if format != FormatJSON {
+		return content, nil
+	}
+
+	file, diags := hclsyntax.ParseConfig([]byte(content), "generated.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", diags
+	}
+
+	result, err := bodyToJSON(file.Body.(*hclsyntax.Body))
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(b) + "\n", nil
+}
+
+// bodyToJSON maps a body to the JSON syntax of HCL, where labeled blocks become nested objects
+// and repeated blocks of the same type become an array
+func bodyToJSON(body *hclsyntax.Body) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+
+	for name, attr := range body.Attributes {
+		v, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		b, err := ctyjson.Marshal(v, v.Type())
+		if err != nil {
+			return nil, err
+		}
+		result[name] = json.RawMessage(b)
+	}
+
+	blocks := map[string][]interface{}{}
+	for _, block := range body.Blocks {
+		content, err := bodyToJSON(block.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{} = content
+		for i := len(block.Labels) - 1; i >= 0; i-- {
+			value = map[string]interface{}{block.Labels[i]: value}
+		}
+
+		blocks[block.Type] = append(blocks[block.Type], value)
+	}
+
+	for name, values := range blocks {
+		if len(values) == 1 {
+			result[name] = values[0]
+		} else {
+			result[name] = values
+		}
+	}
+
+	return result, nil
+}
@@ -21,6 +21,13 @@ func makeAbsolute(path string, base string) string {
 	return base + "/" + filename
 }
 
+func valueOrDefault(value string, def string) string {
+	if len(value) == 0 {
+		return def
+	}
+	return value
+}
+
 func expandPath(path string) string {
 	res, _ := homedir.Expand(path)
 	return res
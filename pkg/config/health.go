@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// HealthEndpoint is the HTTP endpoint on the target reporting whether an agent is ready, together with the TLS files
+// to reach it. Without a CA file, a https endpoint is requested without verifying the certificate of the local agent.
+type HealthEndpoint struct {
+	URL      string
+	CaFile   string
+	CertFile string
+	KeyFile  string
+	// Unhealthy is a successful response reporting the agent is not ready yet, like a Consul agent without a leader
+	Unhealthy string
+}
+
+// overridesListener reports whether one of the settings changes an attribute or block of the given names,
+// an endpoint built from the flags alone can't be trusted when the address, port or TLS settings of the listener are changed
+func overridesListener(settings []Setting, names ...string) bool {
+	for _, s := range settings {
+		for _, name := range names {
+			if s.Path[0] == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// healthHost returns the host to reach a listener, bound to the given address, from the target itself
+func healthHost(addr string) string {
+	fields := strings.Fields(addr)
+	if len(fields) == 0 {
+		return "127.0.0.1"
+	}
+
+	host := strings.Trim(fields[0], "[]")
+	if strings.Contains(host, "{{") || net.ParseIP(host).IsUnspecified() {
+		return "127.0.0.1"
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// healthURL builds the URL of a health endpoint on a listener with an address optionally including a port
+func healthURL(scheme, addr string, port string, path string) string {
+	if host, p, err := net.SplitHostPort(addr); err == nil {
+		addr, port = host, p
+	}
+	return fmt.Sprintf("%s://%s:%s%s", scheme, healthHost(addr), port, path)
+}
@@ -19,19 +19,22 @@ type NomadConfig struct {
 	CertFile        string
 	KeyFile         string
 	EnableACL       bool
+	DataDir         string
+	ConfigDir       string
+	Settings        []Setting
 }
 
 func (c NomadConfig) EnableTLS() bool {
 	return len(c.CaFile) != 0 && len(c.CertFile) != 0 && len(c.KeyFile) != 0
 }
 
-func (c NomadConfig) GenerateConfigFile() string {
+func (c NomadConfig) GenerateConfigFile() (string, error) {
 
 	f := hclwrite.NewEmptyFile()
 	rootBody := f.Body()
 
 	rootBody.SetAttributeValue("datacenter", cty.StringVal(c.Datacenter))
-	rootBody.SetAttributeValue("data_dir", cty.StringVal("/opt/nomad"))
+	rootBody.SetAttributeValue("data_dir", cty.StringVal(c.dataDir()))
 
 	if len(c.BindAddr) != 0 {
 		addressesBlock := rootBody.AppendNewBlock("addresses", []string{})
@@ -82,9 +85,9 @@ func (c NomadConfig) GenerateConfigFile() string {
 		tlsBlock := rootBody.AppendNewBlock("tls", []string{})
 		tlsBlock.Body().SetAttributeValue("http", cty.BoolVal(true))
 		tlsBlock.Body().SetAttributeValue("rpc", cty.BoolVal(true))
-		tlsBlock.Body().SetAttributeValue("ca_file", cty.StringVal(makeAbsolute(c.CaFile, "/etc/nomad.d")))
-		tlsBlock.Body().SetAttributeValue("cert_file", cty.StringVal(makeAbsolute(c.CertFile, "/etc/nomad.d")))
-		tlsBlock.Body().SetAttributeValue("key_file", cty.StringVal(makeAbsolute(c.KeyFile, "/etc/nomad.d")))
+		tlsBlock.Body().SetAttributeValue("ca_file", cty.StringVal(makeAbsolute(c.CaFile, c.configDir())))
+		tlsBlock.Body().SetAttributeValue("cert_file", cty.StringVal(makeAbsolute(c.CertFile, c.configDir())))
+		tlsBlock.Body().SetAttributeValue("key_file", cty.StringVal(makeAbsolute(c.KeyFile, c.configDir())))
 	}
 
 	if c.EnableACL {
@@ -92,5 +95,33 @@ func (c NomadConfig) GenerateConfigFile() string {
 		aclBlock.Body().SetAttributeValue("enabled", cty.BoolVal(true))
 	}
 
-	return generate(f)
+	if err := applySettings(rootBody, c.Settings); err != nil {
+		return "", err
+	}
+
+	return generate(f), nil
+}
+
+// HealthEndpoint returns the agent health endpoint of the HTTP API, no endpoint is returned when the settings change the listener
+func (c NomadConfig) HealthEndpoint() HealthEndpoint {
+	if overridesListener(c.Settings, "bind_addr", "addresses", "ports", "tls") {
+		return HealthEndpoint{}
+	}
+	if c.EnableTLS() {
+		return HealthEndpoint{
+			URL:      healthURL("https", c.BindAddr, "4646", "/v1/agent/health"),
+			CaFile:   makeAbsolute(c.CaFile, c.configDir()),
+			CertFile: makeAbsolute(c.CertFile, c.configDir()),
+			KeyFile:  makeAbsolute(c.KeyFile, c.configDir()),
+		}
+	}
+	return HealthEndpoint{URL: healthURL("http", c.BindAddr, "4646", "/v1/agent/health")}
+}
+
+func (c NomadConfig) dataDir() string {
+	return valueOrDefault(c.DataDir, "/opt/nomad")
+}
+
+func (c NomadConfig) configDir() string {
+	return valueOrDefault(c.ConfigDir, "/etc/nomad.d")
 }
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// sensitiveName matches the names of the attributes holding a secret, like the gossip encryption key, the tokens of a tokens block or a KMS key
+var sensitiveName = regexp.MustCompile(`^(encrypt|token|key|url|password|secret|agent|default|initial_management|master|replication|agent_recovery|[A-Za-z0-9_-]*_(token|key|password|secret))$`)
+
+// SensitiveAttribute reports whether an attribute with the given name holds a secret
+func SensitiveAttribute(name string) bool {
+	return sensitiveName.MatchString(name)
+}
+
+// Setting is an arbitrary attribute, addressed by a dotted path, to write into a generated configuration file
+type Setting struct {
+	Path  []string
+	Value cty.Value
+}
+
+// ParseSettings parses a list of path.to.key=value expressions.
+//
+// The value is interpreted as an HCL expression, e.g. 8500, true, ["a", "b"] or "text",
+// and falls back to a plain string when it is not a valid literal, e.g. 0.0.0.0 or info
+func ParseSettings(values []string) ([]Setting, error) {
+	var result []Setting
+	for _, v := range values {
+		s, err := ParseSetting(v)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// Sensitive reports whether the setting holds a secret, by the name of its attribute or of the tokens block containing it
+func (s Setting) Sensitive() bool {
+	for _, p := range s.Path[:len(s.Path)-1] {
+		if p == "tokens" {
+			return true
+		}
+	}
+	return SensitiveAttribute(s.Path[len(s.Path)-1])
+}
+
+func ParseSetting(value string) (Setting, error) {
+	key, raw, found := strings.Cut(value, "=")
+	if !found {
+		return Setting{}, fmt.Errorf("invalid setting '%s', expected format is path.to.key=value", value)
+	}
+
+	path := strings.Split(strings.TrimSpace(key), ".")
+	for _, p := range path {
+		if !hclsyntax.ValidIdentifier(p) {
+			return Setting{}, fmt.Errorf("invalid setting '%s', '%s' is not a valid attribute or block name", value, key)
+		}
+	}
+
+	return Setting{Path: path, Value: parseValue(strings.TrimSpace(raw))}, nil
+}
+
+// parseValue returns a literal number, bool, quoted string or list of literals as it is, any other value, like an expression
+// such as a version range 1-2, is kept as a string
+func parseValue(raw string) cty.Value {
+	expr, diags := hclsyntax.ParseExpression([]byte(raw), "", hcl.InitialPos)
+	if diags.HasErrors() || !literal(expr) {
+		return cty.StringVal(raw)
+	}
+
+	v, diags := expr.Value(nil)
+	if diags.HasErrors() || !v.IsWhollyKnown() || v.IsNull() {
+		return cty.StringVal(raw)
+	}
+
+	return v
+}
+
+func literal(expr hclsyntax.Expression) bool {
+	switch e := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		return true
+	case *hclsyntax.TemplateExpr:
+		return e.IsStringLiteral()
+	case *hclsyntax.UnaryOpExpr:
+		// a negative number
+		_, ok := e.Val.(*hclsyntax.LiteralValueExpr)
+		return ok && e.Op == hclsyntax.OpNegate
+	case *hclsyntax.TupleConsExpr:
+		for _, v := range e.Exprs {
+			if !literal(v) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// applySettings writes the settings into a generated configuration, creating the blocks of a path that do not exist yet.
+// A path addresses a block by its type, including a single labeled block like listener "tcp", so a setting is refused
+// when the type matches several blocks, or when a name is used by an attribute where a block is expected or the other way around.
+func applySettings(body *hclwrite.Body, settings []Setting) error {
+	for _, s := range settings {
+		current := body
+		for i, name := range s.Path[:len(s.Path)-1] {
+			next, err := findOrCreateBlock(current, name, strings.Join(s.Path[:i+1], "."))
+			if err != nil {
+				return fmt.Errorf("invalid setting %s: %s", strings.Join(s.Path, "."), err)
+			}
+			current = next
+		}
+
+		name := s.Path[len(s.Path)-1]
+		for _, b := range current.Blocks() {
+			if b.Type() == name {
+				return fmt.Errorf("invalid setting %s: %s is a block, not an attribute", strings.Join(s.Path, "."), strings.Join(s.Path, "."))
+			}
+		}
+		current.SetAttributeValue(name, s.Value)
+	}
+	return nil
+}
+
+// findOrCreateBlock returns the only block of the given type, or a new block without labels when there is no such block
+func findOrCreateBlock(body *hclwrite.Body, name string, path string) (*hclwrite.Body, error) {
+	if body.GetAttribute(name) != nil {
+		return nil, fmt.Errorf("%s is an attribute, not a block", path)
+	}
+
+	var found []*hclwrite.Block
+	for _, b := range body.Blocks() {
+		if b.Type() == name {
+			found = append(found, b)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return body.AppendNewBlock(name, []string{}).Body(), nil
+	case 1:
+		return found[0].Body(), nil
+	default:
+		return nil, fmt.Errorf("%s matches %d blocks, use a custom configuration file instead", path, len(found))
+	}
+}
@@ -18,6 +18,9 @@ type VaultConfig struct {
 	ConsulCaFile   string
 	ConsulCertFile string
 	ConsulKeyFile  string
+	DataDir        string
+	ConfigDir      string
+	Settings       []Setting
 }
 
 func (c VaultConfig) EnableTLS() bool {
@@ -28,7 +31,7 @@ func (c VaultConfig) EnableConsulTLS() bool {
 	return len(c.ConsulCaFile) != 0 && len(c.ConsulCertFile) != 0 && len(c.ConsulKeyFile) != 0
 }
 
-func (c VaultConfig) GenerateConfigFile() string {
+func (c VaultConfig) GenerateConfigFile() (string, error) {
 	f := hclwrite.NewEmptyFile()
 	rootBody := f.Body()
 
@@ -37,7 +40,7 @@ func (c VaultConfig) GenerateConfigFile() string {
 	storageBlock := rootBody.AppendNewBlock("storage", []string{c.Storage})
 
 	if c.Storage == "file" {
-		storageBlock.Body().SetAttributeValue("path", cty.StringVal("/opt/vault"))
+		storageBlock.Body().SetAttributeValue("path", cty.StringVal(c.dataDir()))
 	}
 
 	if c.Storage == "consul" {
@@ -50,9 +53,9 @@ func (c VaultConfig) GenerateConfigFile() string {
 
 		if c.EnableConsulTLS() {
 			storageBlock.Body().SetAttributeValue("scheme", cty.StringVal("https"))
-			storageBlock.Body().SetAttributeValue("tls_ca_file", cty.StringVal(makeAbsolute(c.ConsulCaFile, "/etc/vault.d")))
-			storageBlock.Body().SetAttributeValue("tls_cert_file", cty.StringVal(makeAbsolute(c.ConsulCertFile, "/etc/vault.d")))
-			storageBlock.Body().SetAttributeValue("tls_key_file", cty.StringVal(makeAbsolute(c.ConsulKeyFile, "/etc/vault.d")))
+			storageBlock.Body().SetAttributeValue("tls_ca_file", cty.StringVal(makeAbsolute(c.ConsulCaFile, c.configDir())))
+			storageBlock.Body().SetAttributeValue("tls_cert_file", cty.StringVal(makeAbsolute(c.ConsulCertFile, c.configDir())))
+			storageBlock.Body().SetAttributeValue("tls_key_file", cty.StringVal(makeAbsolute(c.ConsulKeyFile, c.configDir())))
 		}
 	}
 
@@ -70,12 +73,41 @@ func (c VaultConfig) GenerateConfigFile() string {
 
 		if c.EnableTLS() {
 			listenerBlock.Body().SetAttributeValue("tls_disable", cty.BoolVal(false))
-			listenerBlock.Body().SetAttributeValue("tls_cert_file", cty.StringVal(makeAbsolute(c.CertFile, "/etc/vault.d")))
-			listenerBlock.Body().SetAttributeValue("tls_key_file", cty.StringVal(makeAbsolute(c.KeyFile, "/etc/vault.d")))
+			listenerBlock.Body().SetAttributeValue("tls_cert_file", cty.StringVal(makeAbsolute(c.CertFile, c.configDir())))
+			listenerBlock.Body().SetAttributeValue("tls_key_file", cty.StringVal(makeAbsolute(c.KeyFile, c.configDir())))
 		} else {
 			listenerBlock.Body().SetAttributeValue("tls_disable", cty.BoolVal(true))
 		}
 	}
 
-	return generate(f)
+	if err := applySettings(rootBody, c.Settings); err != nil {
+		return "", err
+	}
+
+	return generate(f), nil
+}
+
+// HealthEndpoint returns the health endpoint of the first listener, reporting a sealed or uninitialized Vault as ready
+// as those states require an operator; no endpoint is returned when the settings change the listener
+func (c VaultConfig) HealthEndpoint() HealthEndpoint {
+	if overridesListener(c.Settings, "listener") {
+		return HealthEndpoint{}
+	}
+	var addr string
+	if len(c.Address) != 0 {
+		addr = c.Address[0]
+	}
+	scheme := "http"
+	if c.EnableTLS() {
+		scheme = "https"
+	}
+	return HealthEndpoint{URL: healthURL(scheme, addr, "8200", "/v1/sys/health?standbyok=true&perfstandbyok=true&sealedcode=200&uninitcode=200")}
+}
+
+func (c VaultConfig) dataDir() string {
+	return valueOrDefault(c.DataDir, "/opt/vault")
+}
+
+func (c VaultConfig) configDir() string {
+	return valueOrDefault(c.ConfigDir, "/etc/vault.d")
 }
@@ -64,13 +64,23 @@ func GetLatestVersion(product string) (string, error) {
 }
 
 func GetDownloadURL(product, arch string, version *semver.Version) string {
+	return GetPlatformDownloadURL(product, runtime.GOOS, arch, version)
+}
+
+// GetPlatformDownloadURL returns the URL of the distribution of a product for the given operating system and architecture
+func GetPlatformDownloadURL(product, goos, arch string, version *semver.Version) string {
 	v := semver.MustParse("1.10.4")
 
 	if arch == "arm" && product == "consul" && version.LessThan(v) {
 		arch = "armhfv6"
 	}
 
-	return fmt.Sprintf("https://releases.hashicorp.com/%s/%s/%s_%s_%s_%s.zip", product, version, product, version, runtime.GOOS, arch)
+	return fmt.Sprintf("https://releases.hashicorp.com/%s/%s/%s_%s_%s_%s.zip", product, version, product, version, goos, arch)
+}
+
+// GetChecksumsURL returns the URL of the SHA256SUMS file listing the checksums of all distributions of a product release
+func GetChecksumsURL(product string, version *semver.Version) string {
+	return fmt.Sprintf("https://releases.hashicorp.com/%s/%s/%s_%s_SHA256SUMS", product, version, product, version)
 }
 
 func GetArmSuffix(product string, version string) string {
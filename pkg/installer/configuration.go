@@ -0,0 +1,106 @@
+package installer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jsiebens/hashi-up/pkg/config"
+	"github.com/jsiebens/hashi-up/pkg/operator"
+	"github.com/mitchellh/go-homedir"
+)
+
+// Configuration is the main configuration file of a product, either generated from a product configuration or loaded from a custom file,
+// together with the additional files, e.g. certificates, to upload next to it
+type Configuration struct {
+	Name    string
+	Content string
+	Source  string
+	Files   []string
+}
+
+// FileName returns the name of the configuration file of a product in the given format, hcl or json
+func FileName(product, format string) (string, error) {
+	if err := config.ValidateFormat(format); err != nil {
+		return "", err
+	}
+	return config.FileName(product, format), nil
+}
+
+// GenerateConfiguration converts a generated configuration to the given format
+func GenerateConfiguration(name, format, content string, files []string) (*Configuration, error) {
+	converted, err := config.ConvertFormat(content, format)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert generated configuration to %s: %s", format, err)
+	}
+	return &Configuration{Name: name, Content: converted, Files: files}, nil
+}
+
+// LoadConfiguration reads a custom configuration file, to be installed with the given name
+func LoadConfiguration(name, path string, files []string) (*Configuration, error) {
+	content, err := os.ReadFile(expandPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read configuration file %s: %s", path, err)
+	}
+	return &Configuration{Name: name, Content: string(content), Source: path, Files: files}, nil
+}
+
+// Generated reports whether the configuration is generated, instead of loaded from a custom file
+func (c *Configuration) Generated() bool {
+	return len(c.Source) == 0
+}
+
+// configuration loads the custom configuration file of the options, or else generates the configuration of the product
+func configuration(product string, opts Options, generate func() (string, []string, error)) (*Configuration, error) {
+	format := opts.configFormat()
+
+	name, err := FileName(product, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.ConfigFile) != 0 {
+		return LoadConfiguration(name, opts.ConfigFile, opts.Files)
+	}
+
+	content, files, err := generate()
+	if err != nil {
+		return nil, err
+	}
+
+	return GenerateConfiguration(name, format, content, files)
+}
+
+// Upload uploads the configuration and its additional files to the config directory in the given directory on the target
+func (c *Configuration) Upload(op operator.CommandOperator, dir string, title string, out io.Writer) error {
+	if c.Generated() {
+		info(out, fmt.Sprintf("Uploading generated %s configuration ...", title))
+	} else {
+		info(out, fmt.Sprintf("Uploading %s as %s...", c.Source, c.Name))
+	}
+
+	err := op.Upload(strings.NewReader(c.Content), dir+"/config/"+c.Name, "0640")
+	if err != nil {
+		return fmt.Errorf("error received during upload %s configuration: %s", strings.ToLower(title), err)
+	}
+
+	for _, s := range c.Files {
+		if len(s) != 0 {
+			info(out, fmt.Sprintf("Uploading %s...", s))
+			_, filename := filepath.Split(expandPath(s))
+			err = op.UploadFile(expandPath(s), dir+"/config/"+filename, "0640")
+			if err != nil {
+				return fmt.Errorf("error received during upload file: %s", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func expandPath(path string) string {
+	res, _ := homedir.Expand(path)
+	return res
+}
@@ -0,0 +1,162 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/jsiebens/hashi-up/pkg/archive"
+	"github.com/jsiebens/hashi-up/pkg/config"
+	"github.com/jsiebens/hashi-up/pkg/operator"
+)
+
+// UploadBinary downloads the distribution of a product on the local machine, verifies it against the published
+// checksums and uploads the extracted binary to the given directory on the target, so the target doesn't need curl, unzip or sha256sum
+func UploadBinary(op operator.CommandOperator, dir string, product string, version string, out io.Writer) error {
+	title := strings.Title(product)
+
+	semVersion, err := semver.NewVersion(version)
+	if err != nil {
+		return err
+	}
+
+	arch, err := targetArch(op)
+	if err != nil {
+		return err
+	}
+
+	downloadURL := config.GetPlatformDownloadURL(product, "linux", arch, semVersion)
+
+	info(out, fmt.Sprintf("Downloading %s ...", path.Base(downloadURL)))
+	file, err := downloadFile(downloadURL)
+	if err != nil {
+		return fmt.Errorf("unable to download %s distribution: %s", title, err)
+	}
+	defer os.Remove(file)
+
+	info(out, fmt.Sprintf("Verifying %s ...", path.Base(downloadURL)))
+	if err := verifyChecksum(file, path.Base(downloadURL), config.GetChecksumsURL(product, semVersion)); err != nil {
+		return err
+	}
+
+	binary, err := archive.Open(file, product)
+	if err != nil {
+		return err
+	}
+	defer binary.Close()
+
+	info(out, fmt.Sprintf("Uploading %s binary ...", title))
+	err = op.Upload(binary, dir+"/"+product, "0755")
+	if err != nil {
+		return fmt.Errorf("error received during upload %s binary: %s", title, err)
+	}
+
+	return nil
+}
+
+// targetArch maps the machine hardware name of the target to the architecture used by the releases
+func targetArch(op operator.CommandOperator) (string, error) {
+	res, err := op.ExecuteWithOutput("uname -m")
+	if err != nil {
+		return "", fmt.Errorf("unable to detect architecture of target: %s", err)
+	}
+
+	machine := strings.TrimSpace(string(res.StdOut))
+	switch {
+	case machine == "x86_64" || machine == "amd64":
+		return "amd64", nil
+	case machine == "aarch64" || machine == "arm64":
+		return "arm64", nil
+	case strings.HasPrefix(machine, "arm"):
+		return "arm", nil
+	default:
+		return "", fmt.Errorf("unsupported architecture %s", machine)
+	}
+}
+
+// downloadFile downloads a file to a temporary file, which is to be removed by the caller
+func downloadFile(downloadURL string) (string, error) {
+	res, err := http.DefaultClient.Get(downloadURL)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("incorrect status for downloading %s: %d", downloadURL, res.StatusCode)
+	}
+
+	out, err := os.CreateTemp("", "hashi-up-*-"+path.Base(downloadURL))
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, res.Body); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+
+	return out.Name(), nil
+}
+
+func verifyChecksum(file, name, checksumsURL string) error {
+	res, err := http.DefaultClient.Get(checksumsURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("incorrect status for downloading %s: %d", checksumsURL, res.StatusCode)
+	}
+
+	checksums, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var expected string
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == name {
+			expected = fields[0]
+		}
+	}
+
+	if len(expected) == 0 {
+		return fmt.Errorf("unable to find checksum of %s in %s", name, checksumsURL)
+	}
+
+	actual, err := FileHash(file)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected {
+		return fmt.Errorf("checksum mismatch for %s, expected %s but got %s", name, expected, actual)
+	}
+
+	return nil
+}
+
+// FileHash returns the sha256 checksum of a local file, as printed by sha256sum
+func FileHash(path string) (string, error) {
+	f, err := os.Open(expandPath(path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
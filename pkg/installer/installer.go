@@ -0,0 +1,375 @@
+// Package installer installs HashiCorp products on a target via SSH, or on the local machine, with the same
+// scripts as the hashi-up CLI, for programs embedding hashi-up instead of running the binary.
+//
+// The context of an installation is only checked between its stages: a cancelled context stops the installation before it connects,
+// uploads or runs the install script, but doesn't interrupt a running upload or install script, which would leave the target half installed.
+package installer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/jsiebens/hashi-up/pkg/config"
+	"github.com/jsiebens/hashi-up/pkg/operator"
+	"github.com/jsiebens/hashi-up/scripts"
+	"github.com/thanhpk/randstr"
+)
+
+const defaultWaitTimeout = 60 * time.Second
+
+// Options are the parameters of an installation, besides the configuration of the product itself;
+// the zero value installs the latest version with the default layout and user of the product and waits until the product is healthy
+type Options struct {
+	// Version to install, the latest version when empty and no package is set
+	Version string
+	// Package is a local release archive uploaded to the target, instead of downloading the release on the target
+	Package string
+	// LocalDownload downloads and verifies the release on this machine and uploads the binary to the target
+	LocalDownload bool
+
+	SkipConfig       bool
+	SkipEnable       bool
+	SkipStart        bool
+	SkipValidate     bool
+	SkipDependencies bool
+
+	// ConfigFile is a local configuration file uploaded instead of generating the configuration,
+	// together with the additional Files, e.g. certificates
+	ConfigFile string
+	Files      []string
+	// ConfigFormat of the generated configuration file, hcl or json, hcl when empty; a custom ConfigFile is installed
+	// in its own format, taken from its extension, and can't be combined with another format
+	ConfigFormat string
+
+	// Layout of the installation, an empty field takes the default of the product, e.g. an empty User is the user
+	// returned by DefaultUser; set User to root to run the service as root
+	Layout Layout
+	// InitSystem managing the service, auto detected on the target when empty
+	InitSystem string
+	// Environment is the content of the environment file of the service
+	Environment string
+	Service     Service
+
+	// NoWait returns once the service is (re)started, instead of waiting at most WaitTimeout until the product is healthy
+	NoWait      bool
+	WaitTimeout time.Duration
+
+	// Record holds the parameters of the installation to keep on the target, besides the version and the checksums of the installed files
+	Record *Record
+
+	// Stdout and Stderr receive the progress of the installation and the output of the install script, which is discarded when nil
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Service customizes the generated systemd unit of a product with drop-in files
+type Service struct {
+	// Args are appended to the ExecStart command of the service
+	Args []string
+	// DropIn is the content of the drop-in file managed by hashi-up
+	DropIn string
+	// DropInFiles are local drop-in files uploaded next to it
+	DropInFiles []string
+}
+
+// Result describes a successful installation
+type Result struct {
+	Target  string
+	Product string
+	// Version installed, empty when installed from a package
+	Version string
+	// Configuration installed, nil when the configuration was skipped
+	Configuration *Configuration
+	// Files are the sha256 checksums of the installed configuration and environment files
+	Files    map[string]string
+	Duration time.Duration
+}
+
+// Stage is the step of an installation
+type Stage string
+
+const (
+	StageValidate Stage = "validate"
+	StageConnect  Stage = "connect"
+	StageUpload   Stage = "upload"
+	StageInstall  Stage = "install"
+)
+
+// Error is returned when an installation fails, the target is left untouched unless the install stage was reached
+type Error struct {
+	Target  string
+	Product string
+	Stage   Stage
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (o *Options) configFormat() string {
+	if len(o.ConfigFile) != 0 {
+		return config.FileFormat(o.ConfigFile)
+	}
+	if len(o.ConfigFormat) == 0 {
+		return config.FormatHCL
+	}
+	return o.ConfigFormat
+}
+
+// install validates the options and resolves the configuration before connecting, uploads everything to a temporary directory
+// on the target and runs the install script of the product
+func install(ctx context.Context, product string, target Target, opts Options, resolve func(Options) (*Configuration, config.HealthEndpoint, error)) (*Result, error) {
+	title := strings.Title(product)
+
+	stage := StageValidate
+	fail := func(err error) (*Result, error) {
+		return nil, &Error{Target: target.Name(), Product: product, Stage: stage, Err: err}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fail(err)
+	}
+
+	if err := target.validate(); err != nil {
+		return fail(err)
+	}
+
+	opts.Layout = opts.Layout.withDefaults(product)
+	if err := opts.Layout.Validate(); err != nil {
+		return fail(err)
+	}
+
+	initSystem := opts.InitSystem
+	if len(initSystem) == 0 {
+		initSystem = "auto"
+	}
+	if err := ValidateInitSystem(initSystem); err != nil {
+		return fail(err)
+	}
+
+	timeout := opts.WaitTimeout
+	if timeout == 0 {
+		timeout = defaultWaitTimeout
+	}
+	if !opts.NoWait && timeout < time.Second {
+		return fail(fmt.Errorf("invalid wait-timeout '%s', at least 1s is required", timeout))
+	}
+
+	if len(opts.Package) != 0 && opts.LocalDownload {
+		return fail(fmt.Errorf("the package and local-download flags can not be used together"))
+	}
+
+	version := opts.Version
+	if len(opts.Package) == 0 && len(version) == 0 {
+		latest, err := config.GetLatestVersion(product)
+		if err != nil {
+			return fail(fmt.Errorf("unable to get latest version number, define a version manually: %s", err))
+		}
+		version = latest
+	}
+
+	armSuffix := ""
+	if len(version) != 0 {
+		if _, err := semver.NewVersion(version); err != nil {
+			return fail(fmt.Errorf("invalid version '%s': %s", version, err))
+		}
+		armSuffix = config.GetArmSuffix(product, version)
+	}
+
+	if len(opts.ConfigFile) != 0 && len(opts.ConfigFormat) != 0 && opts.ConfigFormat != opts.configFormat() {
+		return fail(fmt.Errorf("the config-format %s does not match the configuration file %s, a configuration file is installed in its own format", opts.ConfigFormat, opts.ConfigFile))
+	}
+
+	configFile, err := FileName(product, opts.configFormat())
+	if err != nil {
+		return fail(err)
+	}
+
+	var configuration *Configuration
+	var endpoint config.HealthEndpoint
+	if !opts.SkipConfig {
+		configuration, endpoint, err = resolve(opts)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	for _, d := range opts.Service.DropInFiles {
+		if _, filename := filepath.Split(expandPath(d)); filename == "hashi-up.conf" {
+			return fail(fmt.Errorf("the systemd drop-in file name hashi-up.conf is reserved, please rename %s", d))
+		}
+	}
+
+	hashes, err := files(product, configuration, opts.Environment)
+	if err != nil {
+		return fail(err)
+	}
+
+	record := Record{}
+	if opts.Record != nil {
+		record = *opts.Record
+	}
+	if len(record.HashiUpVersion) == 0 {
+		record.HashiUpVersion = "dev"
+	}
+	if record.Flags == nil {
+		record.Flags = map[string]interface{}{}
+	}
+	record.Product = product
+	record.Version = version
+	record.InstalledAt = time.Now().UTC().Truncate(time.Second)
+	record.Files = hashes
+
+	stdout, stderr := opts.Stdout, opts.Stderr
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	if configuration != nil && len(endpoint.URL) == 0 && !opts.NoWait {
+		info(stdout, fmt.Sprintf("The health endpoint of the %s configuration can't be determined, e.g. from a custom configuration file or settings changing its listeners, only waiting for the service to keep running", title))
+	}
+
+	callback := func(op operator.CommandOperator) error {
+		stage = StageUpload
+
+		dir := "/tmp/hashi-up." + randstr.String(6)
+
+		defer op.Execute("rm -rf " + dir)
+
+		err := op.Execute("mkdir -p " + dir + "/config " + dir + "/systemd")
+		if err != nil {
+			return fmt.Errorf("error received during installation: %s", err)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if len(opts.Package) != 0 {
+			info(stdout, fmt.Sprintf("Uploading %s package ...", title))
+			err = op.UploadFile(expandPath(opts.Package), dir+"/"+product+".zip", "0640")
+			if err != nil {
+				return fmt.Errorf("error received during upload %s package: %s", title, err)
+			}
+		}
+
+		if opts.LocalDownload {
+			if err := UploadBinary(op, dir, product, version, stdout); err != nil {
+				return err
+			}
+		}
+
+		if configuration != nil {
+			if err := configuration.Upload(op, dir, title, stdout); err != nil {
+				return err
+			}
+		}
+
+		if len(opts.Environment) != 0 {
+			info(stdout, fmt.Sprintf("Uploading %s.env ...", product))
+			err = op.Upload(strings.NewReader(opts.Environment), dir+"/config/"+product+".env", "0600")
+			if err != nil {
+				return fmt.Errorf("error received during upload environment file: %s", err)
+			}
+		}
+
+		if err := record.upload(op, dir); err != nil {
+			return err
+		}
+
+		if len(opts.Service.DropIn) != 0 {
+			info(stdout, "Uploading generated systemd drop-in file ...")
+			err = op.Upload(strings.NewReader(opts.Service.DropIn), dir+"/systemd/hashi-up.conf", "0644")
+			if err != nil {
+				return fmt.Errorf("error received during upload systemd drop-in file: %s", err)
+			}
+		}
+
+		for _, d := range opts.Service.DropInFiles {
+			info(stdout, fmt.Sprintf("Uploading %s...", d))
+			_, filename := filepath.Split(expandPath(d))
+			err = op.UploadFile(expandPath(d), dir+"/systemd/"+filename, "0644")
+			if err != nil {
+				return fmt.Errorf("error received during upload systemd drop-in file: %s", err)
+			}
+		}
+
+		data := map[string]interface{}{
+			"TmpDir":           dir,
+			"SkipEnable":       opts.SkipEnable,
+			"SkipStart":        opts.SkipStart,
+			"SkipValidate":     opts.SkipValidate,
+			"SkipDependencies": opts.SkipDependencies,
+			"Version":          version,
+			"ConfigFile":       configFile,
+			"ServiceArgs":      strings.Join(opts.Service.Args, " "),
+			"InitSystem":       initSystem,
+			"ArmSuffix":        armSuffix,
+			"Wait":             !opts.NoWait,
+			"WaitTimeout":      int(timeout.Seconds()),
+			"Health":           endpoint,
+			"DataDir":          opts.Layout.DataDir,
+			"ConfigDir":        opts.Layout.ConfigDir,
+			"BinDir":           opts.Layout.BinDir,
+			"User":             opts.Layout.User,
+			"Group":            opts.Layout.ServiceGroup(),
+		}
+
+		installScript, err := scripts.RenderScript("install_"+product+".sh", data)
+		if err != nil {
+			return err
+		}
+
+		err = op.Upload(installScript, dir+"/install.sh", "0755")
+		if err != nil {
+			return fmt.Errorf("error received during upload install script: %s", err)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		stage = StageInstall
+
+		info(stdout, fmt.Sprintf("Installing %s ...", title))
+		err = op.ExecuteWithWriter(fmt.Sprintf("cat %s/install.sh | SUDO_PASS=\"%s\" sh -\n", dir, target.sudoPass()), stdout, stderr)
+		if err != nil {
+			return fmt.Errorf("error received during installation: %s", err)
+		}
+
+		return nil
+	}
+
+	start := time.Now()
+
+	stage = StageConnect
+	if err := target.execute(callback); err != nil {
+		return fail(err)
+	}
+
+	return &Result{
+		Target:        target.Name(),
+		Product:       product,
+		Version:       version,
+		Configuration: configuration,
+		Files:         hashes,
+		Duration:      time.Since(start),
+	}, nil
+}
+
+func info(out io.Writer, message string) {
+	fmt.Fprintln(out, "[INFO] "+message)
+}
@@ -0,0 +1,154 @@
+package installer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	validDir  = regexp.MustCompile(`^/[A-Za-z0-9._/-]*$`)
+	validName = regexp.MustCompile(`^[a-z_][a-z0-9_-]*[$]?$`)
+)
+
+// systemDirs are never accepted as a directory owned by hashi-up, as the scripts change the owner of those directories and uninstall removes them
+var systemDirs = map[string]bool{
+	"/": true, "/bin": true, "/boot": true, "/dev": true, "/etc": true, "/home": true, "/lib": true, "/lib32": true, "/lib64": true,
+	"/media": true, "/mnt": true, "/opt": true, "/proc": true, "/root": true, "/run": true, "/sbin": true, "/srv": true, "/sys": true,
+	"/tmp": true, "/usr": true, "/var": true,
+	"/etc/systemd": true, "/etc/init.d": true, "/etc/ssh": true, "/etc/sudoers.d": true,
+	"/usr/bin": true, "/usr/lib": true, "/usr/lib64": true, "/usr/local": true, "/usr/local/bin": true, "/usr/sbin": true, "/usr/share": true,
+	"/var/cache": true, "/var/lib": true, "/var/log": true, "/var/run": true, "/var/tmp": true,
+}
+
+// systemPrefixes hold the virtual and boot file systems, no directory below them is accepted
+var systemPrefixes = []string{"/boot/", "/dev/", "/proc/", "/sys/"}
+
+var initSystems = []string{"auto", "systemd", "openrc", "sysv"}
+
+// defaultUsers are the system users running the services by default, a Nomad client requires root so Nomad runs as root by default
+var defaultUsers = map[string]string{
+	"consul":   "consul",
+	"vault":    "vault",
+	"boundary": "boundary",
+}
+
+// Layout holds the directories and the system user and group of a product installation on the target
+type Layout struct {
+	DataDir   string
+	ConfigDir string
+	BinDir    string
+	// User running the service, created when it doesn't exist; the default user of the product when empty, root for Nomad
+	User string
+	// Group of the user, the name of the user when empty
+	Group string
+}
+
+// Validate verifies the layout is safe to use in the scripts and removes trailing slashes from the directories
+func (l *Layout) Validate() error {
+	for flag, dir := range map[string]*string{"data-dir": &l.DataDir, "config-dir": &l.ConfigDir} {
+		if len(*dir) == 0 {
+			continue
+		}
+		clean, err := ValidateDir(flag, *dir)
+		if err != nil {
+			return err
+		}
+		*dir = clean
+	}
+	if len(l.BinDir) != 0 {
+		clean, err := validatePath("bin-dir", l.BinDir)
+		if err != nil {
+			return err
+		}
+		l.BinDir = clean
+	}
+	if len(l.DataDir) != 0 && len(l.ConfigDir) != 0 && nested(l.DataDir, l.ConfigDir) {
+		return fmt.Errorf("the config-dir '%s' and the data-dir '%s' must be different directories, not nested in each other", l.ConfigDir, l.DataDir)
+	}
+	for flag, name := range map[string]string{"user": l.User, "group": l.Group} {
+		if len(name) != 0 && !validName.MatchString(name) {
+			return fmt.Errorf("invalid %s '%s'", flag, name)
+		}
+	}
+	if len(l.Group) != 0 && len(l.User) == 0 {
+		return fmt.Errorf("a group requires a user to run the service")
+	}
+	return nil
+}
+
+// ValidateDir verifies a directory owned by hashi-up, like a config or data directory, is safe to use in the scripts
+// and returns it without trailing slashes; a system directory is refused, as the scripts change its owner and uninstall removes it
+func ValidateDir(flag string, dir string) (string, error) {
+	clean, err := validatePath(flag, dir)
+	if err != nil {
+		return "", err
+	}
+	if systemDirs[clean] {
+		return "", fmt.Errorf("invalid %s '%s', a system directory can not be used, use a dedicated directory like /opt/<product> instead", flag, dir)
+	}
+	return clean, nil
+}
+
+// validatePath verifies a path is safe to use in the scripts and returns it without trailing slashes
+func validatePath(flag string, dir string) (string, error) {
+	if !validDir.MatchString(dir) {
+		return "", fmt.Errorf("invalid %s '%s', an absolute path without spaces or special characters is required", flag, dir)
+	}
+	clean := filepath.Clean(dir)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid %s '%s', the root directory can not be used", flag, dir)
+	}
+	for _, prefix := range systemPrefixes {
+		if strings.HasPrefix(clean+"/", prefix) {
+			return "", fmt.Errorf("invalid %s '%s', a directory in %s can not be used", flag, dir, strings.TrimSuffix(prefix, "/"))
+		}
+	}
+	return clean, nil
+}
+
+// nested reports whether two clean directories are the same or one contains the other
+func nested(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// ServiceGroup returns the group running the service, which defaults to the name of the user
+func (l *Layout) ServiceGroup() string {
+	if len(l.Group) != 0 {
+		return l.Group
+	}
+	return l.User
+}
+
+// DefaultUser returns the system user running the service of a product by default, an empty user means root
+func DefaultUser(product string) string {
+	return defaultUsers[product]
+}
+
+// withDefaults fills the directories and the user left empty with the defaults of the product
+func (l Layout) withDefaults(product string) Layout {
+	if len(l.User) == 0 {
+		l.User = DefaultUser(product)
+	}
+	if len(l.DataDir) == 0 {
+		l.DataDir = fmt.Sprintf("/opt/%s", product)
+	}
+	if len(l.ConfigDir) == 0 {
+		l.ConfigDir = fmt.Sprintf("/etc/%s.d", product)
+	}
+	if len(l.BinDir) == 0 {
+		l.BinDir = "/usr/local/bin"
+	}
+	return l
+}
+
+// ValidateInitSystem verifies the init system is supported by the scripts, auto lets the scripts detect it on the target
+func ValidateInitSystem(name string) error {
+	for _, s := range initSystems {
+		if name == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid init-system '%s', supported are auto, systemd, openrc and sysv", name)
+}
@@ -0,0 +1,177 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jsiebens/hashi-up/pkg/config"
+)
+
+// ConsulConfiguration returns the Consul configuration to install with the given options, the custom configuration file of the options
+// or else the configuration generated from cfg, with the directories of the layout of the options
+func ConsulConfiguration(cfg config.ConsulConfig, opts Options) (*Configuration, error) {
+	return configuration("consul", opts, func() (string, []string, error) {
+		cfg.DataDir = opts.Layout.DataDir
+		cfg.ConfigDir = opts.Layout.ConfigDir
+
+		var files []string
+		if cfg.EnableTLS() {
+			files = []string{cfg.CaFile, cfg.CertFile, cfg.KeyFile}
+		}
+
+		content, err := cfg.GenerateConfigFile()
+		return content, files, err
+	})
+}
+
+// NomadConfiguration returns the Nomad configuration to install with the given options, the custom configuration file of the options
+// or else the configuration generated from cfg, with the directories of the layout of the options
+func NomadConfiguration(cfg config.NomadConfig, opts Options) (*Configuration, error) {
+	return configuration("nomad", opts, func() (string, []string, error) {
+		cfg.DataDir = opts.Layout.DataDir
+		cfg.ConfigDir = opts.Layout.ConfigDir
+
+		var files []string
+		if cfg.EnableTLS() {
+			files = []string{cfg.CaFile, cfg.KeyFile, cfg.CertFile}
+		}
+
+		content, err := cfg.GenerateConfigFile()
+		return content, files, err
+	})
+}
+
+// VaultConfiguration returns the Vault configuration to install with the given options, the custom configuration file of the options
+// or else the configuration generated from cfg, with the directories of the layout of the options
+func VaultConfiguration(cfg config.VaultConfig, opts Options) (*Configuration, error) {
+	return configuration("vault", opts, func() (string, []string, error) {
+		cfg.DataDir = opts.Layout.DataDir
+		cfg.ConfigDir = opts.Layout.ConfigDir
+
+		var files []string
+		if cfg.EnableTLS() {
+			files = append(files, cfg.KeyFile, cfg.CertFile)
+		}
+		if cfg.EnableConsulTLS() {
+			files = append(files, cfg.ConsulCaFile, cfg.ConsulCertFile, cfg.ConsulKeyFile)
+		}
+
+		content, err := cfg.GenerateConfigFile()
+		return content, files, err
+	})
+}
+
+// BoundaryConfiguration returns the Boundary configuration to install with the given options, the custom configuration file of the options
+// or else the configuration generated from cfg, with the configuration directory of the layout of the options
+func BoundaryConfiguration(cfg config.BoundaryConfig, opts Options) (*Configuration, error) {
+	return configuration("boundary", opts, func() (string, []string, error) {
+		cfg.ConfigDir = opts.Layout.ConfigDir
+
+		if !(cfg.IsControllerEnabled() || cfg.IsWorkerEnabled()) {
+			return "", nil, fmt.Errorf("a controller-name and/or a worker-name is required")
+		}
+
+		if cfg.IsControllerEnabled() {
+			if !cfg.HasDatabaseURL() {
+				return "", nil, fmt.Errorf("a db-url is required when running a controller")
+			}
+			if !cfg.HasAllRequiredControllerKeys() {
+				return "", nil, fmt.Errorf("a root-key, a worker-auth-key and a recovery-key are required when running a controller")
+			}
+		}
+
+		if cfg.IsWorkerEnabled() && !cfg.HasAllRequiredWorkerKeys() {
+			return "", nil, fmt.Errorf("a worker-auth-key are required when running a worker")
+		}
+
+		if !cfg.HasValidApiTLSSettings() {
+			return "", nil, fmt.Errorf("both api-key-file and api-cert-file are required to enable API TLS")
+		}
+
+		if !cfg.HasValidClusterTLSSettings() {
+			return "", nil, fmt.Errorf("both cluster-key-file and cluster-cert-file are required to enable cluster TLS")
+		}
+
+		if !cfg.HasValidProxyTLSSettings() {
+			return "", nil, fmt.Errorf("both proxy-key-file and proxy-cert-file are required to enable proxy TLS")
+		}
+
+		var files []string
+		if cfg.ApiTLSEnabled() {
+			files = append(files, cfg.ApiCertFile, cfg.ApiKeyFile)
+		}
+		if cfg.ClusterTLSEnabled() {
+			files = append(files, cfg.ClusterKeyFile, cfg.ClusterCertFile)
+		}
+		if cfg.ProxyTLSEnabled() {
+			files = append(files, cfg.ProxyKeyFile, cfg.ProxyCertFile)
+		}
+
+		content, err := cfg.GenerateConfigFile()
+		return content, files, err
+	})
+}
+
+// InstallConsul installs Consul on the target with the configuration generated from cfg, or the custom configuration file of the options
+func InstallConsul(ctx context.Context, target Target, cfg config.ConsulConfig, opts Options) (*Result, error) {
+	return install(ctx, "consul", target, opts, func(opts Options) (*Configuration, config.HealthEndpoint, error) {
+		cfg.DataDir = opts.Layout.DataDir
+		cfg.ConfigDir = opts.Layout.ConfigDir
+
+		c, err := ConsulConfiguration(cfg, opts)
+		if err != nil || !c.Generated() {
+			return c, config.HealthEndpoint{}, err
+		}
+		return c, cfg.HealthEndpoint(), nil
+	})
+}
+
+// InstallNomad installs Nomad on the target with the configuration generated from cfg, or the custom configuration file of the options
+func InstallNomad(ctx context.Context, target Target, cfg config.NomadConfig, opts Options) (*Result, error) {
+	return install(ctx, "nomad", target, opts, func(opts Options) (*Configuration, config.HealthEndpoint, error) {
+		cfg.DataDir = opts.Layout.DataDir
+		cfg.ConfigDir = opts.Layout.ConfigDir
+
+		c, err := NomadConfiguration(cfg, opts)
+		if err != nil || !c.Generated() {
+			return c, config.HealthEndpoint{}, err
+		}
+		return c, cfg.HealthEndpoint(), nil
+	})
+}
+
+// InstallVault installs Vault on the target with the configuration generated from cfg, or the custom configuration file of the options
+func InstallVault(ctx context.Context, target Target, cfg config.VaultConfig, opts Options) (*Result, error) {
+	return install(ctx, "vault", target, opts, func(opts Options) (*Configuration, config.HealthEndpoint, error) {
+		cfg.DataDir = opts.Layout.DataDir
+		cfg.ConfigDir = opts.Layout.ConfigDir
+
+		c, err := VaultConfiguration(cfg, opts)
+		if err != nil || !c.Generated() {
+			return c, config.HealthEndpoint{}, err
+		}
+		return c, cfg.HealthEndpoint(), nil
+	})
+}
+
+// InstallBoundary installs Boundary on the target with the configuration generated from cfg, or the custom configuration file of the options
+func InstallBoundary(ctx context.Context, target Target, cfg config.BoundaryConfig, opts Options) (*Result, error) {
+	return install(ctx, "boundary", target, opts, func(opts Options) (*Configuration, config.HealthEndpoint, error) {
+		cfg.ConfigDir = opts.Layout.ConfigDir
+
+		c, err := BoundaryConfiguration(cfg, opts)
+		if err != nil {
+			return nil, config.HealthEndpoint{}, err
+		}
+		if !c.Generated() {
+			// Boundary has no command to validate a configuration on the target, so the custom configuration is checked before the upload instead
+			if !opts.SkipValidate {
+				if err := config.ValidateBoundaryConfig(c.Source, c.Content, opts.configFormat()); err != nil {
+					return nil, config.HealthEndpoint{}, fmt.Errorf("invalid configuration file %s: %s", c.Source, err)
+				}
+			}
+			return c, config.HealthEndpoint{}, nil
+		}
+		return c, cfg.HealthEndpoint(), nil
+	})
+}
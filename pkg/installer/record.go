@@ -0,0 +1,76 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/jsiebens/hashi-up/pkg/operator"
+)
+
+// Record is written to the target on every installation, to reproduce the installation later on;
+// a flag is recorded as a string, or as a list of strings for a flag accepting multiple values
+type Record struct {
+	HashiUpVersion string                 `json:"hashi_up_version"`
+	Product        string                 `json:"product"`
+	Version        string                 `json:"version,omitempty"`
+	InstalledAt    time.Time              `json:"installed_at"`
+	Flags          map[string]interface{} `json:"flags"`
+	SecretFlags    []string               `json:"secret_flags,omitempty"`
+	Files          map[string]string      `json:"files,omitempty"`
+}
+
+// RecordPath returns the path of the install record of a product on the target
+func RecordPath(product string) string {
+	return fmt.Sprintf("/etc/hashi-up/%s.json", product)
+}
+
+// files returns the checksums of the installed configuration and environment files
+func files(product string, configuration *Configuration, env string) (map[string]string, error) {
+	result := map[string]string{}
+
+	if configuration != nil {
+		result[configuration.Name] = contentHash(configuration.Content)
+		for _, s := range configuration.Files {
+			if len(s) == 0 {
+				continue
+			}
+			hash, err := FileHash(s)
+			if err != nil {
+				return nil, err
+			}
+			_, filename := filepath.Split(expandPath(s))
+			result[filename] = hash
+		}
+	}
+
+	if len(env) != 0 {
+		result[product+".env"] = contentHash(env)
+	}
+
+	return result, nil
+}
+
+// upload writes the record next to the uploaded files, the install script keeps it on success
+func (r *Record) upload(op operator.CommandOperator, dir string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = op.Upload(bytes.NewReader(append(content, '\n')), dir+"/record.json", "0600")
+	if err != nil {
+		return fmt.Errorf("error received during upload install record: %s", err)
+	}
+
+	return nil
+}
+
+func contentHash(content string) string {
+	h := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(h[:])
+}
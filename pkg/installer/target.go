@@ -0,0 +1,47 @@
+package installer
+
+import (
+	"fmt"
+
+	"github.com/jsiebens/hashi-up/pkg/operator"
+)
+
+// Target is the machine to install on, either via SSH or the local machine itself
+type Target struct {
+	Addr     string
+	User     string
+	Key      string
+	Password string
+	SudoPass string
+	Local    bool
+}
+
+// Name identifies the target in results and errors
+func (t *Target) Name() string {
+	if t.Local {
+		return "localhost"
+	}
+	return t.Addr
+}
+
+func (t *Target) validate() error {
+	if !t.Local && len(t.Addr) == 0 {
+		return fmt.Errorf("an address is required for a remote target")
+	}
+	return nil
+}
+
+func (t *Target) execute(callback operator.Callback) error {
+	if t.Local {
+		return operator.ExecuteLocal(callback)
+	}
+	return operator.ExecuteRemote(t.Addr, t.User, t.Key, t.Password, callback)
+}
+
+// sudoPass returns the password for sudo on the target, which defaults to the SSH password
+func (t *Target) sudoPass() string {
+	if len(t.SudoPass) != 0 {
+		return t.SudoPass
+	}
+	return t.Password
+}
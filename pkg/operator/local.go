@@ -1,8 +1,10 @@
 package operator
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"strconv"
 
 	goexecute "github.com/alexellis/go-execute/pkg/v1"
@@ -30,6 +32,44 @@ func (e LocalOperator) Execute(command string) error {
 	return nil
 }
 
+func (e LocalOperator) ExecuteWithOutput(command string) (CommandRes, error) {
+	task := goexecute.ExecTask{
+		Command: command,
+		Shell:   true,
+	}
+
+	res, err := task.Execute()
+	if err != nil {
+		return CommandRes{}, err
+	}
+
+	result := CommandRes{
+		StdOut: []byte(res.Stdout),
+		StdErr: []byte(res.Stderr),
+	}
+
+	if res.ExitCode != 0 {
+		return result, fmt.Errorf("command exited with status %d", res.ExitCode)
+	}
+
+	return result, nil
+}
+
+func (e LocalOperator) ExecuteWithWriter(command string, stdout io.Writer, stderr io.Writer) error {
+	cmd := exec.Command("/bin/bash", "-c", command)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("command exited with status %d", exitErr.ExitCode())
+		}
+		return err
+	}
+
+	return nil
+}
+
 func (e LocalOperator) UploadFile(path string, remotePath string, mode string) error {
 	source, err := os.Open(expandPath(path))
 	if err != nil {
@@ -23,6 +23,8 @@ type CommandRes struct {
 
 type CommandOperator interface {
 	Execute(command string) error
+	ExecuteWithOutput(command string) (CommandRes, error)
+	ExecuteWithWriter(command string, stdout io.Writer, stderr io.Writer) error
 	Upload(src io.Reader, remotePath string, mode string) error
 	UploadFile(path string, remotePath string, mode string) error
 }
@@ -1,6 +1,7 @@
 package operator
 
 import (
+	"bytes"
 	"context"
 	"github.com/bramvdbogaerde/go-scp"
 	"io"
@@ -45,6 +46,40 @@ func (s SSHOperator) Execute(command string) error {
 	return err
 }
 
+func (s SSHOperator) ExecuteWithOutput(command string) (CommandRes, error) {
+	sess, err := s.conn.NewSession()
+	if err != nil {
+		return CommandRes{}, err
+	}
+
+	defer sess.Close()
+
+	var stdout, stderr bytes.Buffer
+	sess.Stdout = &stdout
+	sess.Stderr = &stderr
+	err = sess.Run(command)
+
+	return CommandRes{
+		StdOut: stdout.Bytes(),
+		StdErr: stderr.Bytes(),
+	}, err
+}
+
+func (s SSHOperator) ExecuteWithWriter(command string, stdout io.Writer, stderr io.Writer) error {
+	sess, err := s.conn.NewSession()
+	if err != nil {
+		return err
+	}
+
+	defer sess.Close()
+
+	sess.Stdout = stdout
+	sess.Stderr = stderr
+	err = sess.Run(command)
+
+	return err
+}
+
 func (s SSHOperator) Upload(source io.Reader, remotePath string, mode string) error {
 	sess, err := s.conn.NewSession()
 	if err != nil {
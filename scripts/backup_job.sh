@@ -0,0 +1,41 @@
+#!/bin/sh
+# generated by hashi-up, takes a snapshot of {{.Product}} and keeps the {{.Keep}} most recent ones
+set -e
+
+info() {
+  echo '[INFO] ->' "$@"
+}
+
+fatal() {
+  echo '[ERROR] ->' "$@"
+  exit 1
+}
+
+{{template "cli" .}}
+SUDO=
+CONFIG_DIR={{.ConfigDir}}
+BIN_DIR={{.BinDir}}
+BACKUP_DIR={{.BackupDir}}
+KEEP={{.Keep}}
+
+# --- remove the oldest snapshots, keeping the most recent ones ---
+prune_snapshots() {
+  ls -1t ${BACKUP_DIR}/{{.Product}}-*.snap 2>/dev/null | tail -n +$((KEEP + 1)) | while read -r old; do
+    info "Removing snapshot ${old}"
+    rm -f "${old}" "${old}.sha256"
+  done
+}
+
+umask 077
+mkdir -p ${BACKUP_DIR}
+
+NAME={{.Product}}-$(date -u +%Y%m%dT%H%M%SZ).snap
+trap 'rm -f ${BACKUP_DIR}/.${NAME}.tmp' EXIT
+
+setup_cli_env {{.Product}}
+snapshot {{.Product}} save ${BACKUP_DIR}/.${NAME}.tmp
+mv ${BACKUP_DIR}/.${NAME}.tmp ${BACKUP_DIR}/${NAME}
+(cd ${BACKUP_DIR} && sha256sum ${NAME} > ${NAME}.sha256)
+info "Saved snapshot ${BACKUP_DIR}/${NAME}"
+
+prune_snapshots
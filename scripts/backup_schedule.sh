@@ -0,0 +1,87 @@
+#!/bin/bash
+set -e
+
+info() {
+  echo '[INFO] ->' "$@"
+}
+
+fatal() {
+  echo '[ERROR] ->' "$@"
+  exit 1
+}
+
+{{template "init" .}}
+{{template "state" .}}
+{{template "backup" .}}
+setup_env() {
+  SUDO=sudo
+  if [ "$(id -u)" -eq 0 ]; then
+    SUDO=
+  else
+    if [ ! -z "$SUDO_PASS" ]; then
+      echo $SUDO_PASS | sudo -S true
+      echo ""
+    fi
+  fi
+
+  INIT_SYSTEM=systemd
+
+  BACKUP_UNIT=$(backup_unit $SERVICE)
+  BACKUP_JOB=$(backup_job $SERVICE)
+  BACKUP_DIR={{.BackupDir}}
+  EVERY={{.Every}}
+}
+
+verify_system() {
+  if ! [ -x "$(command -v systemctl)" ] || ! [ -d /run/systemd/system ]; then
+    fatal "Scheduled snapshots require systemd"
+  fi
+  if ! [ -f "$(service_file $SERVICE)" ]; then
+    fatal "No $SERVICE service found, install $SERVICE before scheduling snapshots"
+  fi
+}
+
+install_backup_schedule() {
+  info "Installing job ${BACKUP_JOB}"
+  $SUDO mkdir --parents $(state_dir)
+  $SUDO cp $TMP_DIR/backup.sh ${BACKUP_JOB}
+  $SUDO chmod 0700 ${BACKUP_JOB}
+  $SUDO mkdir --parents ${BACKUP_DIR}
+  $SUDO chmod 0700 ${BACKUP_DIR}
+
+  info "Creating systemd units ${BACKUP_UNIT}.service and ${BACKUP_UNIT}.timer"
+  $SUDO tee /etc/systemd/system/${BACKUP_UNIT}.service >/dev/null <<SERVICE
+[Unit]
+Description=Snapshot of HashiCorp {{.Title}}
+After=$SERVICE.service
+
+[Service]
+Type=oneshot
+ExecStart=${BACKUP_JOB}
+SERVICE
+
+  $SUDO tee /etc/systemd/system/${BACKUP_UNIT}.timer >/dev/null <<TIMER
+[Unit]
+Description=Periodic snapshot of HashiCorp {{.Title}}
+
+[Timer]
+OnActiveSec=${EVERY}
+OnUnitActiveSec=${EVERY}
+
+[Install]
+WantedBy=timers.target
+TIMER
+
+  $SUDO systemctl daemon-reload
+  $SUDO systemctl enable ${BACKUP_UNIT}.timer >/dev/null
+  $SUDO systemctl restart ${BACKUP_UNIT}.timer
+  info "Taking a snapshot every ${EVERY} into ${BACKUP_DIR}"
+}
+
+setup_env
+if [ "$ACTION" = remove ]; then
+  remove_backup_schedule $SERVICE
+else
+  verify_system
+  install_backup_schedule
+fi
@@ -10,12 +10,7 @@ fatal() {
   exit 1
 }
 
-verify_system() {
-  if ! [ -d /run/systemd ]; then
-    fatal 'Can not find systemd to use as a process supervisor for Boundary'
-  fi
-}
-
+{{template "init" .}}
 setup_env() {
   SUDO=sudo
   if [ "$(id -u)" -eq 0 ]; then
@@ -27,17 +22,34 @@ setup_env() {
     fi
   fi
 
-  BOUNDARY_DATA_DIR=/opt/boundary
-  BOUNDARY_CONFIG_DIR=/etc/boundary.d
-  BOUNDARY_SERVICE_FILE=/etc/systemd/system/boundary.service
+  INIT_SYSTEM={{.InitSystem}}
+  detect_init_system
+
+  BOUNDARY_DATA_DIR={{.DataDir}}
+  BOUNDARY_CONFIG_DIR={{.ConfigDir}}
+  BOUNDARY_SERVICE_FILE=$(service_file boundary)
+  BOUNDARY_DROPIN_DIR=/etc/systemd/system/boundary.service.d
+  BOUNDARY_CONFIG_FILE=${BOUNDARY_CONFIG_DIR}/{{.ConfigFile}}
+  BOUNDARY_USER={{.User}}
+  BOUNDARY_GROUP={{.Group}}
 
-  BIN_DIR=/usr/local/bin
+  BIN_DIR={{.BinDir}}
 
   PRE_INSTALL_HASHES=$(get_installed_hashes)
 
   TMP_DIR={{.TmpDir}}
+  BOUNDARY_CONFIG_FILE=$(installed_config_file boundary ${BOUNDARY_CONFIG_DIR} ${BOUNDARY_CONFIG_FILE})
   SKIP_ENABLE={{.SkipEnable}}
   SKIP_START={{.SkipStart}}
+  SKIP_VALIDATE={{.SkipValidate}}
+  SKIP_DEPENDENCIES={{.SkipDependencies}}
+  WAIT={{.Wait}}
+  WAIT_TIMEOUT={{.WaitTimeout}}
+  HEALTH_URL="{{.Health.URL}}"
+  HEALTH_CA_FILE={{.Health.CaFile}}
+  HEALTH_CERT_FILE={{.Health.CertFile}}
+  HEALTH_KEY_FILE={{.Health.KeyFile}}
+  HEALTH_UNHEALTHY='{{.Health.Unhealthy}}'
   BOUNDARY_VERSION={{.Version}}
 
   cd $TMP_DIR
@@ -72,39 +84,32 @@ setup_verify_arch() {
 
 # --- get hashes of the current boundary bin and service files
 get_installed_hashes() {
-  $SUDO sha256sum ${BIN_DIR}/boundary ${BOUNDARY_CONFIG_DIR}/* ${BOUNDARY_SERVICE_FILE} 2>&1 || true
-}
-
-has_yum() {
-  [ -n "$(command -v yum)" ]
-}
-
-has_apt_get() {
-  [ -n "$(command -v apt-get)" ]
-}
-
-install_dependencies() {
-  if [ ! -x "${TMP_DIR}/boundary" ]; then
-    if ! [ -x "$(command -v unzip)" ] || ! [ -x "$(command -v curl)" ]; then
-      if $(has_apt_get); then
-        $SUDO apt-get install -y curl unzip
-      elif $(has_yum); then
-        $SUDO yum install -y curl unzip
-      else
-        fatal "Could not find apt-get or yum. Cannot install dependencies on this OS"
-        exit 1
-      fi
-    fi
-  fi
+  $SUDO sha256sum ${BIN_DIR}/boundary ${BOUNDARY_CONFIG_DIR}/* ${BOUNDARY_SERVICE_FILE} ${BOUNDARY_DROPIN_DIR}/* 2>&1 || true
 }
 
+{{template "dependencies" .}}
+{{template "releases" .}}
+{{template "state" .}}
+{{template "health" .}}
 download_and_install() {
   if [ -f "${TMP_DIR}/boundary.zip" ]; then
+    BOUNDARY_RELEASE_DIR=$(releases_dir boundary)/package-$(sha256sum "${TMP_DIR}/boundary.zip" | cut -c1-12)
+  else
+    BOUNDARY_RELEASE_DIR=$(releases_dir boundary)/${BOUNDARY_VERSION}
+  fi
+  $SUDO mkdir --parents ${BOUNDARY_RELEASE_DIR}
+
+  if [ -f "${TMP_DIR}/boundary" ]; then
+    info "Installing uploaded Boundary binary"
+    $SUDO cp "${TMP_DIR}/boundary" "${BOUNDARY_RELEASE_DIR}/boundary.new"
+    $SUDO chmod 0755 "${BOUNDARY_RELEASE_DIR}/boundary.new"
+    $SUDO mv -f "${BOUNDARY_RELEASE_DIR}/boundary.new" "${BOUNDARY_RELEASE_DIR}/boundary"
+  elif [ -f "${TMP_DIR}/boundary.zip" ]; then
     info "Installing uploaded Boundary package"
-    $SUDO unzip -qq -o "$TMP_DIR/boundary.zip" -d $BIN_DIR
+    $SUDO unzip -qq -o "$TMP_DIR/boundary.zip" -d ${BOUNDARY_RELEASE_DIR}
   else
-    if [ -x "${BIN_DIR}/boundary" ] && [ "$(${BIN_DIR}/boundary version | grep "Version Number" | tr -s ' ' | cut -d' ' -f4)" = "${BOUNDARY_VERSION}" ]; then
-      info "Boundary binary already installed in ${BIN_DIR}, skipping downloading and installing binary"
+    if [ -x "${BOUNDARY_RELEASE_DIR}/boundary" ]; then
+      info "Boundary ${BOUNDARY_VERSION} already installed in ${BOUNDARY_RELEASE_DIR}, skipping downloading and installing binary"
     else
       info "Downloading boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
       curl -o "$TMP_DIR/boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip" -sfL "https://releases.hashicorp.com/boundary/${BOUNDARY_VERSION}/boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
@@ -116,17 +121,51 @@ download_and_install() {
       sha256sum -c "$TMP_DIR/boundary_${BOUNDARY_VERSION}_SHA256SUMS"
 
       info "Unpacking boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
-      $SUDO unzip -qq -o "$TMP_DIR/boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip" -d $BIN_DIR
+      $SUDO unzip -qq -o "$TMP_DIR/boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip" -d ${BOUNDARY_RELEASE_DIR}
     fi
   fi
 }
 
+# --- stage the uploaded config files on top of the current ones, to validate them before they replace the current files ---
+stage_config() {
+  $SUDO mkdir -m 0700 ${TMP_DIR}/staging
+  trap '$SUDO rm -rf ${TMP_DIR}/staging' EXIT
+
+  BOUNDARY_STAGING_DIR=${TMP_DIR}/staging/boundary.d
+  if [ -d "${BOUNDARY_CONFIG_DIR}" ]; then
+    $SUDO cp -a ${BOUNDARY_CONFIG_DIR} ${BOUNDARY_STAGING_DIR}
+  else
+    $SUDO mkdir ${BOUNDARY_STAGING_DIR}
+  fi
+
+  if [ "$(ls -A ${TMP_DIR}/config/)" ]; then
+    if [ -f "${TMP_DIR}/config/{{.ConfigFile}}" ]; then
+      $SUDO rm -f ${BOUNDARY_STAGING_DIR}/boundary.hcl ${BOUNDARY_STAGING_DIR}/boundary.json
+    fi
+    $SUDO cp ${TMP_DIR}/config/* ${BOUNDARY_STAGING_DIR}
+  fi
+
+  # the files the configuration refers to are verified, so the staged configuration refers to the staged files
+  if [ -f "${BOUNDARY_STAGING_DIR}/$(basename ${BOUNDARY_CONFIG_FILE})" ]; then
+    $SUDO sed -i "s|${BOUNDARY_CONFIG_DIR}/|${BOUNDARY_STAGING_DIR}/|g" ${BOUNDARY_STAGING_DIR}/$(basename ${BOUNDARY_CONFIG_FILE})
+  fi
+}
+
 create_user_and_config() {
-  if $(id boundary >/dev/null 2>&1); then
-    info "User 'boundary' already exists, will not create again"
+  if $(getent group ${BOUNDARY_GROUP} >/dev/null 2>&1); then
+    info "Group '${BOUNDARY_GROUP}' already exists, will not create again"
   else
-    info "Creating user named 'boundary'"
-    $SUDO useradd --system --home ${BOUNDARY_CONFIG_DIR} --shell /bin/false boundary
+    info "Creating group named '${BOUNDARY_GROUP}'"
+    add_system_group ${BOUNDARY_GROUP}
+    record_account boundary group ${BOUNDARY_GROUP}
+  fi
+
+  if $(id ${BOUNDARY_USER} >/dev/null 2>&1); then
+    info "User '${BOUNDARY_USER}' already exists, will not create again"
+  else
+    info "Creating user named '${BOUNDARY_USER}'"
+    add_system_user ${BOUNDARY_USER} ${BOUNDARY_GROUP} ${BOUNDARY_CONFIG_DIR}
+    record_account boundary user ${BOUNDARY_USER}
   fi
 
   $SUDO mkdir --parents ${BOUNDARY_DATA_DIR}
@@ -134,16 +173,55 @@ create_user_and_config() {
 
   if [ "$(ls -A ${TMP_DIR}/config/)" ]; then
     info "Copying configuration files"
+    if [ -f "${TMP_DIR}/config/{{.ConfigFile}}" ]; then
+      $SUDO rm -f ${BOUNDARY_CONFIG_DIR}/boundary.hcl ${BOUNDARY_CONFIG_DIR}/boundary.json
+    fi
     $SUDO cp ${TMP_DIR}/config/* ${BOUNDARY_CONFIG_DIR}
   fi
 
-  $SUDO chown --recursive boundary:boundary /opt/boundary
-  $SUDO chown --recursive boundary:boundary /etc/boundary.d
+  if [ -f "${BOUNDARY_CONFIG_DIR}/boundary.env" ]; then
+    $SUDO chmod 0600 ${BOUNDARY_CONFIG_DIR}/boundary.env
+  fi
+
+  $SUDO chown --recursive ${BOUNDARY_USER}:${BOUNDARY_GROUP} ${BOUNDARY_DATA_DIR}
+  $SUDO chown --recursive ${BOUNDARY_USER}:${BOUNDARY_GROUP} ${BOUNDARY_CONFIG_DIR}
 }
 
-# --- write systemd service file ---
-create_systemd_service_file() {
-  info "Adding system service file ${BOUNDARY_SERVICE_FILE}"
+# --- boundary has no command to validate a configuration without starting a server, a custom configuration is checked before the upload
+# and the files the staged configuration refers to, like certificates, are verified here ---
+validate_config() {
+  [ "${SKIP_VALIDATE}" = true ] && return
+  $SUDO test -f ${BOUNDARY_STAGING_DIR}/$(basename ${BOUNDARY_CONFIG_FILE}) || return 0
+
+  info "Validating Boundary configuration"
+  MISSING=
+  for FILE in $($SUDO grep -o -E '_file"?[[:space:]]*[=:][[:space:]]*"/[^"]+"' ${BOUNDARY_STAGING_DIR}/$(basename ${BOUNDARY_CONFIG_FILE}) | sed -E 's/.*"([^"]+)"$/\1/'); do
+    $SUDO test -f "${FILE}" || MISSING="${MISSING} $(echo ${FILE} | sed "s|${BOUNDARY_STAGING_DIR}/|${BOUNDARY_CONFIG_DIR}/|")"
+  done
+
+  if [ -n "${MISSING}" ]; then
+    fatal "Boundary configuration refers to missing files:${MISSING}, the current configuration files are left untouched"
+  fi
+}
+
+# --- write service file for the detected init system ---
+create_service_file() {
+  SERVICE_NAME=boundary
+  SERVICE_DESCRIPTION="HashiCorp Boundary - Secure remote access"
+  SERVICE_BIN=${BIN_DIR}/boundary
+  SERVICE_ARGS="server -config ${BOUNDARY_CONFIG_FILE}{{if .ServiceArgs}} {{.ServiceArgs}}{{end}}"
+  SERVICE_USER=${BOUNDARY_USER}
+  SERVICE_GROUP=${BOUNDARY_GROUP}
+  SERVICE_ENV_FILE=${BOUNDARY_CONFIG_DIR}/boundary.env
+  SERVICE_STOP_SIGNAL=TERM
+  SERVICE_NOFILE=65536
+
+  if [ "${INIT_SYSTEM}" != systemd ]; then
+    create_init_script ${BOUNDARY_SERVICE_FILE}
+    return
+  fi
+
+  info "Adding systemd service file ${BOUNDARY_SERVICE_FILE}"
   $SUDO tee ${BOUNDARY_SERVICE_FILE} >/dev/null <<EOF
 [Unit]
 Description=Boundary
@@ -152,10 +230,11 @@ Wants=network-online.target
 After=network-online.target
 
 [Service]
-ExecStart=${BIN_DIR}/boundary server -config ${BOUNDARY_CONFIG_DIR}/boundary.hcl
+EnvironmentFile=-${SERVICE_ENV_FILE}
+ExecStart=${SERVICE_BIN} ${SERVICE_ARGS}
 ExecReload=/bin/kill -s HUP \$MAINPID
-User=boundary
-Group=boundary
+User=${BOUNDARY_USER}
+Group=${BOUNDARY_GROUP}
 LimitMEMLOCK=infinity
 Capabilities=CAP_IPC_LOCK+ep
 CapabilityBoundingSet=CAP_SYSLOG CAP_IPC_LOCK
@@ -165,13 +244,29 @@ WantedBy=multi-user.target
 EOF
 }
 
-# --- startup systemd service ---
-systemd_enable_and_start() {
+# --- write systemd drop-in files, the drop-in generated by hashi-up is replaced on every installation ---
+create_systemd_dropins() {
+  if [ "${INIT_SYSTEM}" != systemd ]; then
+    if [ -d "${TMP_DIR}/systemd" ] && [ "$(ls -A ${TMP_DIR}/systemd/)" ]; then
+      info "Skipping systemd drop-in files, the service is managed by ${INIT_SYSTEM}"
+    fi
+    return
+  fi
+
+  $SUDO rm -f ${BOUNDARY_DROPIN_DIR}/hashi-up.conf
+  if [ -d "${TMP_DIR}/systemd" ] && [ "$(ls -A ${TMP_DIR}/systemd/)" ]; then
+    info "Adding systemd drop-in files to ${BOUNDARY_DROPIN_DIR}"
+    $SUDO mkdir --parents ${BOUNDARY_DROPIN_DIR}
+    $SUDO cp ${TMP_DIR}/systemd/* ${BOUNDARY_DROPIN_DIR}
+  fi
+}
+
+# --- enable and start the service ---
+enable_and_start_service() {
   [ "${SKIP_ENABLE}" = true ] && return
 
-  info "Enabling systemd service"
-  $SUDO systemctl enable ${BOUNDARY_SERVICE_FILE} >/dev/null
-  $SUDO systemctl daemon-reload >/dev/null
+  info "Enabling ${INIT_SYSTEM} service"
+  service_enable boundary
 
   [ "${SKIP_START}" = true ] && return
 
@@ -181,17 +276,23 @@ systemd_enable_and_start() {
     return
   fi
 
-  info "Starting systemd service"
-  $SUDO systemctl restart boundary
+  info "Starting ${INIT_SYSTEM} service"
+  service_action restart boundary
+
+  wait_for_health boundary
 
   return 0
 }
 
 setup_env
 setup_verify_arch
-verify_system
-install_dependencies
-create_user_and_config
+[ -f "${TMP_DIR}/boundary" ] || install_dependencies curl unzip
+stage_config
 download_and_install
-create_systemd_service_file
-systemd_enable_and_start
+validate_config
+create_user_and_config
+activate_release boundary ${BOUNDARY_RELEASE_DIR} ${BOUNDARY_CONFIG_DIR} ${BIN_DIR}
+create_service_file
+create_systemd_dropins
+enable_and_start_service
+save_install_record boundary
@@ -21,10 +21,11 @@ setup_env() {
     fi
   fi
 
-  BIN_DIR=/usr/local/bin
+  BIN_DIR={{.BinDir}}
 
   TMP_DIR={{.TmpDir}}
   BOUNDARY_VERSION={{.Version}}
+  SKIP_DEPENDENCIES={{.SkipDependencies}}
 
   cd $TMP_DIR
 }
@@ -56,58 +57,41 @@ setup_verify_arch() {
   esac
 }
 
-has_yum() {
-  [ -n "$(command -v yum)" ]
-}
-
-has_apt_get() {
-  [ -n "$(command -v apt-get)" ]
-}
-
-install_dependencies() {
-  if [ ! -x "${TMP_DIR}/boundary" ]; then
-    if ! [ -x "$(command -v unzip)" ] || ! [ -x "$(command -v curl)" ]; then
-      if $(has_apt_get); then
-        $SUDO apt-get install -y curl unzip
-      elif $(has_yum); then
-        $SUDO yum install -y curl unzip
-      else
-        fatal "Could not find apt-get or yum. Cannot install dependencies on this OS"
-        exit 1
-      fi
-    fi
-  fi
-}
-
+{{template "dependencies" .}}
+# --- use the installed binary when it has the requested version, otherwise unpack one in the temporary directory ---
 download_and_install() {
-  if [ -f "${TMP_DIR}/boundary.zip" ]; then
-    info "Installing uploaded Boundary package"
-    $SUDO unzip -qq -o "$TMP_DIR/boundary.zip" -d $BIN_DIR
+  BOUNDARY_BIN=${TMP_DIR}/boundary
+
+  if [ -f "${TMP_DIR}/boundary" ]; then
+    info "Using uploaded Boundary binary"
+    chmod 0755 "${TMP_DIR}/boundary"
+  elif [ -f "${TMP_DIR}/boundary.zip" ]; then
+    info "Unpacking uploaded Boundary package"
+    unzip -qq -o "$TMP_DIR/boundary.zip" -d ${TMP_DIR}
+  elif [ -x "${BIN_DIR}/boundary" ] && [ "$(${BIN_DIR}/boundary version | grep "Version Number" | tr -s ' ' | cut -d' ' -f4)" = "${BOUNDARY_VERSION}" ]; then
+    info "Boundary binary already installed in ${BIN_DIR}, skipping downloading binary"
+    BOUNDARY_BIN=${BIN_DIR}/boundary
   else
-    if [ -x "${BIN_DIR}/boundary" ] && [ "$(${BIN_DIR}/boundary version | grep "Version Number" | tr -s ' ' | cut -d' ' -f4)" = "${BOUNDARY_VERSION}" ]; then
-      info "Boundary binary already installed in ${BIN_DIR}, skipping downloading and installing binary"
-    else
-      info "Downloading boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
-      curl -o "$TMP_DIR/boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip" -sfL "https://releases.hashicorp.com/boundary/${BOUNDARY_VERSION}/boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
+    info "Downloading boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
+    curl -o "$TMP_DIR/boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip" -sfL "https://releases.hashicorp.com/boundary/${BOUNDARY_VERSION}/boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
 
-      info "Downloading boundary_${BOUNDARY_VERSION}_SHA256SUMS"
-      curl -o "$TMP_DIR/boundary_${BOUNDARY_VERSION}_SHA256SUMS" -sfL "https://releases.hashicorp.com/boundary/${BOUNDARY_VERSION}/boundary_${BOUNDARY_VERSION}_SHA256SUMS"
-      info "Verifying downloaded boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
-      sed -ni '/linux_'"${SUFFIX}"'.zip/p' "$TMP_DIR/boundary_${BOUNDARY_VERSION}_SHA256SUMS"
-      sha256sum -c "$TMP_DIR/boundary_${BOUNDARY_VERSION}_SHA256SUMS"
+    info "Downloading boundary_${BOUNDARY_VERSION}_SHA256SUMS"
+    curl -o "$TMP_DIR/boundary_${BOUNDARY_VERSION}_SHA256SUMS" -sfL "https://releases.hashicorp.com/boundary/${BOUNDARY_VERSION}/boundary_${BOUNDARY_VERSION}_SHA256SUMS"
+    info "Verifying downloaded boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
+    sed -ni '/linux_'"${SUFFIX}"'.zip/p' "$TMP_DIR/boundary_${BOUNDARY_VERSION}_SHA256SUMS"
+    sha256sum -c "$TMP_DIR/boundary_${BOUNDARY_VERSION}_SHA256SUMS"
 
-      info "Unpacking boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
-      $SUDO unzip -qq -o "$TMP_DIR/boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip" -d $BIN_DIR
-    fi
+    info "Unpacking boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip"
+    unzip -qq -o "$TMP_DIR/boundary_${BOUNDARY_VERSION}_linux_${SUFFIX}.zip" -d ${TMP_DIR}
   fi
 }
 
 init_database() {
-  $SUDO ${BIN_DIR}/boundary database init -config ${TMP_DIR}/config/boundary.hcl
+  $SUDO ${BOUNDARY_BIN} database init -config ${TMP_DIR}/config/boundary.hcl
 }
 
 setup_env
 setup_verify_arch
-install_dependencies
+[ -f "${TMP_DIR}/boundary" ] || install_dependencies curl unzip
 download_and_install
 init_database
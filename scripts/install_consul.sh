@@ -10,12 +10,7 @@ fatal() {
   exit 1
 }
 
-verify_system() {
-  if ! [ -d /run/systemd ]; then
-    fatal 'Can not find systemd to use as a process supervisor for Consul'
-  fi
-}
-
+{{template "init" .}}
 setup_env() {
   SUDO=sudo
   if [ "$(id -u)" -eq 0 ]; then
@@ -27,17 +22,34 @@ setup_env() {
     fi
   fi
 
-  CONSUL_DATA_DIR=/opt/consul
-  CONSUL_CONFIG_DIR=/etc/consul.d
-  CONSUL_SERVICE_FILE=/etc/systemd/system/consul.service
+  INIT_SYSTEM={{.InitSystem}}
+  detect_init_system
+
+  CONSUL_DATA_DIR={{.DataDir}}
+  CONSUL_CONFIG_DIR={{.ConfigDir}}
+  CONSUL_SERVICE_FILE=$(service_file consul)
+  CONSUL_DROPIN_DIR=/etc/systemd/system/consul.service.d
+  CONSUL_CONFIG_FILE=${CONSUL_CONFIG_DIR}/{{.ConfigFile}}
+  CONSUL_USER={{.User}}
+  CONSUL_GROUP={{.Group}}
 
-  BIN_DIR=/usr/local/bin
+  BIN_DIR={{.BinDir}}
 
   PRE_INSTALL_HASHES=$(get_installed_hashes)
 
   TMP_DIR={{.TmpDir}}
+  CONSUL_CONFIG_FILE=$(installed_config_file consul ${CONSUL_CONFIG_DIR} ${CONSUL_CONFIG_FILE})
   SKIP_ENABLE={{.SkipEnable}}
   SKIP_START={{.SkipStart}}
+  SKIP_VALIDATE={{.SkipValidate}}
+  SKIP_DEPENDENCIES={{.SkipDependencies}}
+  WAIT={{.Wait}}
+  WAIT_TIMEOUT={{.WaitTimeout}}
+  HEALTH_URL="{{.Health.URL}}"
+  HEALTH_CA_FILE={{.Health.CaFile}}
+  HEALTH_CERT_FILE={{.Health.CertFile}}
+  HEALTH_KEY_FILE={{.Health.KeyFile}}
+  HEALTH_UNHEALTHY='{{.Health.Unhealthy}}'
   CONSUL_VERSION={{.Version}}
   CONSUL_ARM_SUFFIX={{.ArmSuffix}}
 
@@ -73,39 +85,32 @@ setup_verify_arch() {
 
 # --- get hashes of the current consul bin and service files
 get_installed_hashes() {
-  $SUDO sha256sum ${BIN_DIR}/consul ${CONSUL_CONFIG_DIR}/* ${CONSUL_SERVICE_FILE} 2>&1 || true
-}
-
-has_yum() {
-  [ -n "$(command -v yum)" ]
-}
-
-has_apt_get() {
-  [ -n "$(command -v apt-get)" ]
-}
-
-install_dependencies() {
-  if [ ! -x "${TMP_DIR}/consul" ]; then
-    if ! [ -x "$(command -v unzip)" ] || ! [ -x "$(command -v curl)" ]; then
-      if $(has_apt_get); then
-        $SUDO apt-get install -y curl unzip
-      elif $(has_yum); then
-        $SUDO yum install -y curl unzip
-      else
-        fatal "Could not find apt-get or yum. Cannot install dependencies on this OS"
-        exit 1
-      fi
-    fi
-  fi
+  $SUDO sha256sum ${BIN_DIR}/consul ${CONSUL_CONFIG_DIR}/* ${CONSUL_SERVICE_FILE} ${CONSUL_DROPIN_DIR}/* 2>&1 || true
 }
 
+{{template "dependencies" .}}
+{{template "releases" .}}
+{{template "state" .}}
+{{template "health" .}}
 download_and_install() {
   if [ -f "${TMP_DIR}/consul.zip" ]; then
+    CONSUL_RELEASE_DIR=$(releases_dir consul)/package-$(sha256sum "${TMP_DIR}/consul.zip" | cut -c1-12)
+  else
+    CONSUL_RELEASE_DIR=$(releases_dir consul)/${CONSUL_VERSION}
+  fi
+  $SUDO mkdir --parents ${CONSUL_RELEASE_DIR}
+
+  if [ -f "${TMP_DIR}/consul" ]; then
+    info "Installing uploaded Consul binary"
+    $SUDO cp "${TMP_DIR}/consul" "${CONSUL_RELEASE_DIR}/consul.new"
+    $SUDO chmod 0755 "${CONSUL_RELEASE_DIR}/consul.new"
+    $SUDO mv -f "${CONSUL_RELEASE_DIR}/consul.new" "${CONSUL_RELEASE_DIR}/consul"
+  elif [ -f "${TMP_DIR}/consul.zip" ]; then
     info "Installing uploaded Consul package"
-    $SUDO unzip -qq -o "$TMP_DIR/consul.zip" -d $BIN_DIR
+    $SUDO unzip -qq -o "$TMP_DIR/consul.zip" -d ${CONSUL_RELEASE_DIR}
   else
-    if [ -x "${BIN_DIR}/consul" ] && [ "$(${BIN_DIR}/consul version | grep Consul | cut -d' ' -f2)" = "v${CONSUL_VERSION}" ]; then
-      info "Consul binary already installed in ${BIN_DIR}, skipping downloading and installing binary"
+    if [ -x "${CONSUL_RELEASE_DIR}/consul" ]; then
+      info "Consul ${CONSUL_VERSION} already installed in ${CONSUL_RELEASE_DIR}, skipping downloading and installing binary"
     else
       info "Downloading consul_${CONSUL_VERSION}_linux_${SUFFIX}.zip"
       curl -o "$TMP_DIR/consul_${CONSUL_VERSION}_linux_${SUFFIX}.zip" -sfL "https://releases.hashicorp.com/consul/${CONSUL_VERSION}/consul_${CONSUL_VERSION}_linux_${SUFFIX}.zip"
@@ -117,17 +122,52 @@ download_and_install() {
       sha256sum -c "$TMP_DIR/consul_${CONSUL_VERSION}_SHA256SUMS"
 
       info "Unpacking consul_${CONSUL_VERSION}_linux_${SUFFIX}.zip"
-      $SUDO unzip -qq -o "$TMP_DIR/consul_${CONSUL_VERSION}_linux_${SUFFIX}.zip" -d $BIN_DIR
+      $SUDO unzip -qq -o "$TMP_DIR/consul_${CONSUL_VERSION}_linux_${SUFFIX}.zip" -d ${CONSUL_RELEASE_DIR}
     fi
   fi
 }
 
+# --- stage the uploaded config files on top of the current ones, to validate them before they replace the current files ---
+stage_config() {
+  $SUDO mkdir -m 0700 ${TMP_DIR}/staging
+  trap '$SUDO rm -rf ${TMP_DIR}/staging' EXIT
+
+  CONSUL_STAGING_DIR=${TMP_DIR}/staging/consul.d
+  if [ -d "${CONSUL_CONFIG_DIR}" ]; then
+    $SUDO cp -a ${CONSUL_CONFIG_DIR} ${CONSUL_STAGING_DIR}
+  else
+    $SUDO mkdir ${CONSUL_STAGING_DIR}
+  fi
+  $SUDO mkdir --parents ${CONSUL_STAGING_DIR}/config
+
+  if [ "$(ls -A ${TMP_DIR}/config/)" ]; then
+    if [ -f "${TMP_DIR}/config/{{.ConfigFile}}" ]; then
+      $SUDO rm -f ${CONSUL_STAGING_DIR}/consul.hcl ${CONSUL_STAGING_DIR}/consul.json
+    fi
+    $SUDO cp ${TMP_DIR}/config/* ${CONSUL_STAGING_DIR}
+  fi
+
+  # validate reads the certificates the configuration refers to, so the staged configuration refers to the staged certificates
+  if [ -f "${CONSUL_STAGING_DIR}/$(basename ${CONSUL_CONFIG_FILE})" ]; then
+    $SUDO sed -i "s|${CONSUL_CONFIG_DIR}/|${CONSUL_STAGING_DIR}/|g" ${CONSUL_STAGING_DIR}/$(basename ${CONSUL_CONFIG_FILE})
+  fi
+}
+
 create_user_and_config() {
-  if $(id consul >/dev/null 2>&1); then
-    info "User 'consul' already exists, will not create again"
+  if $(getent group ${CONSUL_GROUP} >/dev/null 2>&1); then
+    info "Group '${CONSUL_GROUP}' already exists, will not create again"
   else
-    info "Creating user named 'consul'"
-    $SUDO useradd --system --home ${CONSUL_CONFIG_DIR} --shell /bin/false consul
+    info "Creating group named '${CONSUL_GROUP}'"
+    add_system_group ${CONSUL_GROUP}
+    record_account consul group ${CONSUL_GROUP}
+  fi
+
+  if $(id ${CONSUL_USER} >/dev/null 2>&1); then
+    info "User '${CONSUL_USER}' already exists, will not create again"
+  else
+    info "Creating user named '${CONSUL_USER}'"
+    add_system_user ${CONSUL_USER} ${CONSUL_GROUP} ${CONSUL_CONFIG_DIR}
+    record_account consul user ${CONSUL_USER}
   fi
 
   $SUDO mkdir --parents ${CONSUL_DATA_DIR}
@@ -135,15 +175,50 @@ create_user_and_config() {
 
   if [ "$(ls -A ${TMP_DIR}/config/)" ]; then
     info "Copying configuration files"
+    if [ -f "${TMP_DIR}/config/{{.ConfigFile}}" ]; then
+      $SUDO rm -f ${CONSUL_CONFIG_DIR}/consul.hcl ${CONSUL_CONFIG_DIR}/consul.json
+    fi
     $SUDO cp ${TMP_DIR}/config/* ${CONSUL_CONFIG_DIR}
   fi
 
-  $SUDO chown --recursive consul:consul /opt/consul
-  $SUDO chown --recursive consul:consul /etc/consul.d
+  if [ -f "${CONSUL_CONFIG_DIR}/consul.env" ]; then
+    $SUDO chmod 0600 ${CONSUL_CONFIG_DIR}/consul.env
+  fi
+
+  $SUDO chown --recursive ${CONSUL_USER}:${CONSUL_GROUP} ${CONSUL_DATA_DIR}
+  $SUDO chown --recursive ${CONSUL_USER}:${CONSUL_GROUP} ${CONSUL_CONFIG_DIR}
 }
 
-# --- write systemd service file ---
-create_systemd_service_file() {
+# --- validate the staged configuration with the installed binary, before it replaces the current configuration ---
+validate_config() {
+  [ "${SKIP_VALIDATE}" = true ] && return
+
+  info "Validating Consul configuration"
+  OUTPUT=$($SUDO ${CONSUL_RELEASE_DIR}/consul validate ${CONSUL_STAGING_DIR}/$(basename ${CONSUL_CONFIG_FILE}) ${CONSUL_STAGING_DIR}/config 2>&1) && STATUS=0 || STATUS=$?
+
+  if [ "${STATUS}" -ne 0 ]; then
+    echo "${OUTPUT}"
+    fatal "Consul configuration is invalid, the current configuration files are left untouched"
+  fi
+}
+
+# --- write service file for the detected init system ---
+create_service_file() {
+  SERVICE_NAME=consul
+  SERVICE_DESCRIPTION="HashiCorp Consul - A service mesh solution"
+  SERVICE_BIN=${BIN_DIR}/consul
+  SERVICE_ARGS="agent -config-file=${CONSUL_CONFIG_FILE} -config-dir=${CONSUL_CONFIG_DIR}/config{{if .ServiceArgs}} {{.ServiceArgs}}{{end}}"
+  SERVICE_USER=${CONSUL_USER}
+  SERVICE_GROUP=${CONSUL_GROUP}
+  SERVICE_ENV_FILE=${CONSUL_CONFIG_DIR}/consul.env
+  SERVICE_STOP_SIGNAL=TERM
+  SERVICE_NOFILE=65536
+
+  if [ "${INIT_SYSTEM}" != systemd ]; then
+    create_init_script ${CONSUL_SERVICE_FILE}
+    return
+  fi
+
   info "Adding systemd service file ${CONSUL_SERVICE_FILE}"
   $SUDO tee ${CONSUL_SERVICE_FILE} >/dev/null <<EOF
 [Unit]
@@ -151,13 +226,13 @@ Description="HashiCorp Consul - A service mesh solution"
 Documentation=https://www.consul.io/
 Requires=network-online.target
 After=network-online.target
-ConditionFileNotEmpty=${CONSUL_CONFIG_DIR}/consul.hcl
+ConditionFileNotEmpty=${CONSUL_CONFIG_FILE}
 
 [Service]
-EnvironmentFile=-/etc/consul.d/consul.env
-User=consul
-Group=consul
-ExecStart=${BIN_DIR}/consul agent -config-file=${CONSUL_CONFIG_DIR}/consul.hcl -config-dir=${CONSUL_CONFIG_DIR}/config
+EnvironmentFile=-${SERVICE_ENV_FILE}
+User=${CONSUL_USER}
+Group=${CONSUL_GROUP}
+ExecStart=${SERVICE_BIN} ${SERVICE_ARGS}
 ExecReload=/bin/kill --signal HUP \$MAINPID
 KillMode=process
 KillSignal=SIGTERM
@@ -169,13 +244,29 @@ WantedBy=multi-user.target
 EOF
 }
 
-# --- startup systemd service ---
-systemd_enable_and_start() {
+# --- write systemd drop-in files, the drop-in generated by hashi-up is replaced on every installation ---
+create_systemd_dropins() {
+  if [ "${INIT_SYSTEM}" != systemd ]; then
+    if [ -d "${TMP_DIR}/systemd" ] && [ "$(ls -A ${TMP_DIR}/systemd/)" ]; then
+      info "Skipping systemd drop-in files, the service is managed by ${INIT_SYSTEM}"
+    fi
+    return
+  fi
+
+  $SUDO rm -f ${CONSUL_DROPIN_DIR}/hashi-up.conf
+  if [ -d "${TMP_DIR}/systemd" ] && [ "$(ls -A ${TMP_DIR}/systemd/)" ]; then
+    info "Adding systemd drop-in files to ${CONSUL_DROPIN_DIR}"
+    $SUDO mkdir --parents ${CONSUL_DROPIN_DIR}
+    $SUDO cp ${TMP_DIR}/systemd/* ${CONSUL_DROPIN_DIR}
+  fi
+}
+
+# --- enable and start the service ---
+enable_and_start_service() {
   [ "${SKIP_ENABLE}" = true ] && return
 
-  info "Enabling systemd service"
-  $SUDO systemctl enable ${CONSUL_SERVICE_FILE} >/dev/null
-  $SUDO systemctl daemon-reload >/dev/null
+  info "Enabling ${INIT_SYSTEM} service"
+  service_enable consul
 
   [ "${SKIP_START}" = true ] && return
 
@@ -185,17 +276,23 @@ systemd_enable_and_start() {
     return
   fi
 
-  info "Starting systemd service"
-  $SUDO systemctl restart consul
+  info "Starting ${INIT_SYSTEM} service"
+  service_action restart consul
+
+  wait_for_health consul
 
   return 0
 }
 
 setup_env
 setup_verify_arch
-verify_system
-install_dependencies
-create_user_and_config
+[ -f "${TMP_DIR}/consul" ] || install_dependencies curl unzip
+stage_config
 download_and_install
-create_systemd_service_file
-systemd_enable_and_start
+validate_config
+create_user_and_config
+activate_release consul ${CONSUL_RELEASE_DIR} ${CONSUL_CONFIG_DIR} ${BIN_DIR}
+create_service_file
+create_systemd_dropins
+enable_and_start_service
+save_install_record consul
@@ -10,12 +10,7 @@ fatal() {
   exit 1
 }
 
-verify_system() {
-  if ! [ -d /run/systemd ]; then
-    fatal 'Can not find systemd to use as a process supervisor for Nomad'
-  fi
-}
-
+{{template "init" .}}
 setup_env() {
   SUDO=sudo
   if [ "$(id -u)" -eq 0 ]; then
@@ -27,17 +22,36 @@ setup_env() {
     fi
   fi
 
-  NOMAD_DATA_DIR=/opt/nomad
-  NOMAD_CONFIG_DIR=/etc/nomad.d
-  NOMAD_SERVICE_FILE=/etc/systemd/system/nomad.service
+  INIT_SYSTEM={{.InitSystem}}
+  detect_init_system
+
+  NOMAD_DATA_DIR={{.DataDir}}
+  NOMAD_CONFIG_DIR={{.ConfigDir}}
+  # the CLI environment of the service is loaded from CONFIG_DIR
+  CONFIG_DIR=${NOMAD_CONFIG_DIR}
+  NOMAD_SERVICE_FILE=$(service_file nomad)
+  NOMAD_DROPIN_DIR=/etc/systemd/system/nomad.service.d
+  NOMAD_CONFIG_FILE=${NOMAD_CONFIG_DIR}/{{.ConfigFile}}
+  NOMAD_USER={{.User}}
+  NOMAD_GROUP={{.Group}}
 
-  BIN_DIR=/usr/local/bin
+  BIN_DIR={{.BinDir}}
 
   PRE_INSTALL_HASHES=$(get_installed_hashes)
 
   TMP_DIR={{.TmpDir}}
+  NOMAD_CONFIG_FILE=$(installed_config_file nomad ${NOMAD_CONFIG_DIR} ${NOMAD_CONFIG_FILE})
   SKIP_ENABLE={{.SkipEnable}}
   SKIP_START={{.SkipStart}}
+  SKIP_VALIDATE={{.SkipValidate}}
+  SKIP_DEPENDENCIES={{.SkipDependencies}}
+  WAIT={{.Wait}}
+  WAIT_TIMEOUT={{.WaitTimeout}}
+  HEALTH_URL="{{.Health.URL}}"
+  HEALTH_CA_FILE={{.Health.CaFile}}
+  HEALTH_CERT_FILE={{.Health.CertFile}}
+  HEALTH_KEY_FILE={{.Health.KeyFile}}
+  HEALTH_UNHEALTHY='{{.Health.Unhealthy}}'
   NOMAD_VERSION={{.Version}}
 
   cd $TMP_DIR
@@ -72,39 +86,34 @@ setup_verify_arch() {
 
 # --- get hashes of the current nomad bin and service files
 get_installed_hashes() {
-  $SUDO sha256sum ${BIN_DIR}/nomad ${NOMAD_CONFIG_DIR}/* ${NOMAD_SERVICE_FILE} 2>&1 || true
-}
-
-has_yum() {
-  [ -n "$(command -v yum)" ]
-}
-
-has_apt_get() {
-  [ -n "$(command -v apt-get)" ]
-}
-
-install_dependencies() {
-  if [ ! -x "${TMP_DIR}/nomad" ]; then
-    if ! [ -x "$(command -v unzip)" ] || ! [ -x "$(command -v curl)" ]; then
-      if $(has_apt_get); then
-        $SUDO apt-get install -y curl unzip
-      elif $(has_yum); then
-        $SUDO yum install -y curl unzip
-      else
-        fatal "Could not find apt-get or yum. Cannot install dependencies on this OS"
-        exit 1
-      fi
-    fi
-  fi
+  $SUDO sha256sum ${BIN_DIR}/nomad ${NOMAD_CONFIG_DIR}/* ${NOMAD_SERVICE_FILE} ${NOMAD_DROPIN_DIR}/* 2>&1 || true
 }
 
+{{template "dependencies" .}}
+{{template "releases" .}}
+{{template "state" .}}
+{{template "health" .}}
+{{template "cli" .}}
+{{template "graceful" .}}
 download_and_install() {
   if [ -f "${TMP_DIR}/nomad.zip" ]; then
+    NOMAD_RELEASE_DIR=$(releases_dir nomad)/package-$(sha256sum "${TMP_DIR}/nomad.zip" | cut -c1-12)
+  else
+    NOMAD_RELEASE_DIR=$(releases_dir nomad)/${NOMAD_VERSION}
+  fi
+  $SUDO mkdir --parents ${NOMAD_RELEASE_DIR}
+
+  if [ -f "${TMP_DIR}/nomad" ]; then
+    info "Installing uploaded Nomad binary"
+    $SUDO cp "${TMP_DIR}/nomad" "${NOMAD_RELEASE_DIR}/nomad.new"
+    $SUDO chmod 0755 "${NOMAD_RELEASE_DIR}/nomad.new"
+    $SUDO mv -f "${NOMAD_RELEASE_DIR}/nomad.new" "${NOMAD_RELEASE_DIR}/nomad"
+  elif [ -f "${TMP_DIR}/nomad.zip" ]; then
     info "Installing uploaded Nomad package"
-    $SUDO unzip -qq -o "$TMP_DIR/nomad.zip" -d $BIN_DIR
+    $SUDO unzip -qq -o "$TMP_DIR/nomad.zip" -d ${NOMAD_RELEASE_DIR}
   else
-    if [ -x "${BIN_DIR}/nomad" ] && [ "$(${BIN_DIR}/nomad version | grep Nomad | cut -d' ' -f2)" = "v${NOMAD_VERSION}" ]; then
-      info "Nomad binary already installed in ${BIN_DIR}, skipping downloading and installing binary"
+    if [ -x "${NOMAD_RELEASE_DIR}/nomad" ]; then
+      info "Nomad ${NOMAD_VERSION} already installed in ${NOMAD_RELEASE_DIR}, skipping downloading and installing binary"
     else
       info "Downloading nomad_${NOMAD_VERSION}_linux_${SUFFIX}.zip"
       curl -o "$TMP_DIR/nomad_${NOMAD_VERSION}_linux_${SUFFIX}.zip" -sfL "https://releases.hashicorp.com/nomad/${NOMAD_VERSION}/nomad_${NOMAD_VERSION}_linux_${SUFFIX}.zip"
@@ -116,23 +125,107 @@ download_and_install() {
       sha256sum -c "$TMP_DIR/nomad_${NOMAD_VERSION}_SHA256SUMS"
 
       info "Unpacking nomad_${NOMAD_VERSION}_linux_${SUFFIX}.zip"
-      $SUDO unzip -qq -o "$TMP_DIR/nomad_${NOMAD_VERSION}_linux_${SUFFIX}.zip" -d $BIN_DIR
+      $SUDO unzip -qq -o "$TMP_DIR/nomad_${NOMAD_VERSION}_linux_${SUFFIX}.zip" -d ${NOMAD_RELEASE_DIR}
+    fi
+  fi
+}
+
+# --- stage the uploaded config files on top of the current ones, to validate them before they replace the current files ---
+stage_config() {
+  $SUDO mkdir -m 0700 ${TMP_DIR}/staging
+  trap '$SUDO rm -rf ${TMP_DIR}/staging' EXIT
+
+  NOMAD_STAGING_DIR=${TMP_DIR}/staging/nomad.d
+  if [ -d "${NOMAD_CONFIG_DIR}" ]; then
+    $SUDO cp -a ${NOMAD_CONFIG_DIR} ${NOMAD_STAGING_DIR}
+  else
+    $SUDO mkdir ${NOMAD_STAGING_DIR}
+  fi
+  $SUDO mkdir --parents ${NOMAD_STAGING_DIR}/config
+
+  if [ "$(ls -A ${TMP_DIR}/config/)" ]; then
+    if [ -f "${TMP_DIR}/config/{{.ConfigFile}}" ]; then
+      $SUDO rm -f ${NOMAD_STAGING_DIR}/nomad.hcl ${NOMAD_STAGING_DIR}/nomad.json
     fi
+    $SUDO cp ${TMP_DIR}/config/* ${NOMAD_STAGING_DIR}
+  fi
+
+  # validate reads the certificates the configuration refers to, so the staged configuration refers to the staged certificates
+  if [ -f "${NOMAD_STAGING_DIR}/$(basename ${NOMAD_CONFIG_FILE})" ]; then
+    $SUDO sed -i "s|${NOMAD_CONFIG_DIR}/|${NOMAD_STAGING_DIR}/|g" ${NOMAD_STAGING_DIR}/$(basename ${NOMAD_CONFIG_FILE})
   fi
 }
 
 create_user_and_config() {
+  if [ -n "${NOMAD_USER}" ]; then
+    if $(getent group ${NOMAD_GROUP} >/dev/null 2>&1); then
+      info "Group '${NOMAD_GROUP}' already exists, will not create again"
+    else
+      info "Creating group named '${NOMAD_GROUP}'"
+      add_system_group ${NOMAD_GROUP}
+      record_account nomad group ${NOMAD_GROUP}
+    fi
+
+    if $(id ${NOMAD_USER} >/dev/null 2>&1); then
+      info "User '${NOMAD_USER}' already exists, will not create again"
+    else
+      info "Creating user named '${NOMAD_USER}'"
+      add_system_user ${NOMAD_USER} ${NOMAD_GROUP} ${NOMAD_CONFIG_DIR}
+      record_account nomad user ${NOMAD_USER}
+    fi
+  fi
+
   $SUDO mkdir --parents ${NOMAD_DATA_DIR}
   $SUDO mkdir --parents ${NOMAD_CONFIG_DIR}/config
 
-    if [ "$(ls -A ${TMP_DIR}/config/)" ]; then
-      info "Copying configuration files"
-      $SUDO cp ${TMP_DIR}/config/* ${NOMAD_CONFIG_DIR}
+  if [ "$(ls -A ${TMP_DIR}/config/)" ]; then
+    info "Copying configuration files"
+    if [ -f "${TMP_DIR}/config/{{.ConfigFile}}" ]; then
+      $SUDO rm -f ${NOMAD_CONFIG_DIR}/nomad.hcl ${NOMAD_CONFIG_DIR}/nomad.json
     fi
+    $SUDO cp ${TMP_DIR}/config/* ${NOMAD_CONFIG_DIR}
+  fi
+
+  if [ -f "${NOMAD_CONFIG_DIR}/nomad.env" ]; then
+    $SUDO chmod 0600 ${NOMAD_CONFIG_DIR}/nomad.env
+  fi
+
+  if [ -n "${NOMAD_USER}" ]; then
+    $SUDO chown --recursive ${NOMAD_USER}:${NOMAD_GROUP} ${NOMAD_DATA_DIR}
+    $SUDO chown --recursive ${NOMAD_USER}:${NOMAD_GROUP} ${NOMAD_CONFIG_DIR}
+  fi
+}
+
+# --- validate the staged configuration with the installed binary, before it replaces the current configuration ---
+validate_config() {
+  [ "${SKIP_VALIDATE}" = true ] && return
+
+  info "Validating Nomad configuration"
+  OUTPUT=$($SUDO ${NOMAD_RELEASE_DIR}/nomad config validate ${NOMAD_STAGING_DIR}/$(basename ${NOMAD_CONFIG_FILE}) ${NOMAD_STAGING_DIR}/config 2>&1) && STATUS=0 || STATUS=$?
+
+  if [ "${STATUS}" -ne 0 ]; then
+    echo "${OUTPUT}"
+    fatal "Nomad configuration is invalid, the current configuration files are left untouched"
+  fi
 }
 
-# --- write systemd service file ---
-create_systemd_service_file() {
+# --- write service file for the detected init system ---
+create_service_file() {
+  SERVICE_NAME=nomad
+  SERVICE_DESCRIPTION="HashiCorp Nomad - A workload orchestrator"
+  SERVICE_BIN=${BIN_DIR}/nomad
+  SERVICE_ARGS="agent -config ${NOMAD_CONFIG_FILE} -config=${NOMAD_CONFIG_DIR}/config{{if .ServiceArgs}} {{.ServiceArgs}}{{end}}"
+  SERVICE_USER=${NOMAD_USER}
+  SERVICE_GROUP=${NOMAD_GROUP}
+  SERVICE_ENV_FILE=${NOMAD_CONFIG_DIR}/nomad.env
+  SERVICE_STOP_SIGNAL=INT
+  SERVICE_NOFILE=65536
+
+  if [ "${INIT_SYSTEM}" != systemd ]; then
+    create_init_script ${NOMAD_SERVICE_FILE}
+    return
+  fi
+
   info "Adding systemd service file ${NOMAD_SERVICE_FILE}"
   $SUDO tee ${NOMAD_SERVICE_FILE} >/dev/null <<EOF
 [Unit]
@@ -142,7 +235,12 @@ Wants=network-online.target
 After=network-online.target
 
 [Service]
-ExecStart=${BIN_DIR}/nomad agent -config ${NOMAD_CONFIG_DIR}/nomad.hcl -config=${NOMAD_CONFIG_DIR}/config
+EnvironmentFile=-${SERVICE_ENV_FILE}
+{{- if .User}}
+User=${NOMAD_USER}
+Group=${NOMAD_GROUP}
+{{- end}}
+ExecStart=${SERVICE_BIN} ${SERVICE_ARGS}
 ExecReload=/bin/kill -s HUP \$MAINPID
 KillMode=process
 KillSignal=SIGINT
@@ -159,13 +257,29 @@ WantedBy=multi-user.target
 EOF
 }
 
-# --- startup systemd service ---
-systemd_enable_and_start() {
+# --- write systemd drop-in files, the drop-in generated by hashi-up is replaced on every installation ---
+create_systemd_dropins() {
+  if [ "${INIT_SYSTEM}" != systemd ]; then
+    if [ -d "${TMP_DIR}/systemd" ] && [ "$(ls -A ${TMP_DIR}/systemd/)" ]; then
+      info "Skipping systemd drop-in files, the service is managed by ${INIT_SYSTEM}"
+    fi
+    return
+  fi
+
+  $SUDO rm -f ${NOMAD_DROPIN_DIR}/hashi-up.conf
+  if [ -d "${TMP_DIR}/systemd" ] && [ "$(ls -A ${TMP_DIR}/systemd/)" ]; then
+    info "Adding systemd drop-in files to ${NOMAD_DROPIN_DIR}"
+    $SUDO mkdir --parents ${NOMAD_DROPIN_DIR}
+    $SUDO cp ${TMP_DIR}/systemd/* ${NOMAD_DROPIN_DIR}
+  fi
+}
+
+# --- enable and start the service ---
+enable_and_start_service() {
   [ "${SKIP_ENABLE}" = true ] && return
 
-  info "Enabling systemd service"
-  $SUDO systemctl enable ${NOMAD_SERVICE_FILE} >/dev/null
-  $SUDO systemctl daemon-reload >/dev/null
+  info "Enabling ${INIT_SYSTEM} service"
+  service_enable nomad
 
   [ "${SKIP_START}" = true ] && return
 
@@ -175,17 +289,24 @@ systemd_enable_and_start() {
     return
   fi
 
-  info "Starting systemd service"
-  $SUDO systemctl restart nomad
+  info "Starting ${INIT_SYSTEM} service"
+  service_action restart nomad
+
+  wait_for_health nomad
+  graceful_start nomad
 
   return 0
 }
 
 setup_env
 setup_verify_arch
-verify_system
-install_dependencies
-create_user_and_config
+[ -f "${TMP_DIR}/nomad" ] || install_dependencies curl unzip
+stage_config
 download_and_install
-create_systemd_service_file
-systemd_enable_and_start
\ No newline at end of file
+validate_config
+create_user_and_config
+activate_release nomad ${NOMAD_RELEASE_DIR} ${NOMAD_CONFIG_DIR} ${BIN_DIR}
+create_service_file
+create_systemd_dropins
+enable_and_start_service
+save_install_record nomad
\ No newline at end of file
@@ -10,12 +10,7 @@ fatal() {
   exit 1
 }
 
-verify_system() {
-  if ! [ -d /run/systemd ]; then
-    fatal 'Can not find systemd to use as a process supervisor for Vault'
-  fi
-}
-
+{{template "init" .}}
 setup_env() {
   SUDO=sudo
   if [ "$(id -u)" -eq 0 ]; then
@@ -27,17 +22,34 @@ setup_env() {
     fi
   fi
 
-  VAULT_DATA_DIR=/opt/vault
-  VAULT_CONFIG_DIR=/etc/vault.d
-  VAULT_SERVICE_FILE=/etc/systemd/system/vault.service
+  INIT_SYSTEM={{.InitSystem}}
+  detect_init_system
+
+  VAULT_DATA_DIR={{.DataDir}}
+  VAULT_CONFIG_DIR={{.ConfigDir}}
+  VAULT_SERVICE_FILE=$(service_file vault)
+  VAULT_DROPIN_DIR=/etc/systemd/system/vault.service.d
+  VAULT_CONFIG_FILE=${VAULT_CONFIG_DIR}/{{.ConfigFile}}
+  VAULT_USER={{.User}}
+  VAULT_GROUP={{.Group}}
 
-  BIN_DIR=/usr/local/bin
+  BIN_DIR={{.BinDir}}
 
   PRE_INSTALL_HASHES=$(get_installed_hashes)
 
   TMP_DIR={{.TmpDir}}
+  VAULT_CONFIG_FILE=$(installed_config_file vault ${VAULT_CONFIG_DIR} ${VAULT_CONFIG_FILE})
   SKIP_ENABLE={{.SkipEnable}}
   SKIP_START={{.SkipStart}}
+  SKIP_VALIDATE={{.SkipValidate}}
+  SKIP_DEPENDENCIES={{.SkipDependencies}}
+  WAIT={{.Wait}}
+  WAIT_TIMEOUT={{.WaitTimeout}}
+  HEALTH_URL="{{.Health.URL}}"
+  HEALTH_CA_FILE={{.Health.CaFile}}
+  HEALTH_CERT_FILE={{.Health.CertFile}}
+  HEALTH_KEY_FILE={{.Health.KeyFile}}
+  HEALTH_UNHEALTHY='{{.Health.Unhealthy}}'
   VAULT_VERSION={{.Version}}
 
   cd $TMP_DIR
@@ -72,40 +84,34 @@ setup_verify_arch() {
 
 # --- get hashes of the current vault bin and service files
 get_installed_hashes() {
-  $SUDO sha256sum ${BIN_DIR}/vault ${VAULT_CONFIG_DIR}/* ${VAULT_SERVICE_FILE} 2>&1 || true
-}
-
-has_yum() {
-  [ -n "$(command -v yum)" ]
-}
-
-has_apt_get() {
-  [ -n "$(command -v apt-get)" ]
-}
-
-install_dependencies() {
-  if [ ! -x "${TMP_DIR}/vault" ]; then
-    if ! [ -x "$(command -v unzip)" ] || ! [ -x "$(command -v curl)" ]; then
-      if $(has_apt_get); then
-        $SUDO apt-get install -y curl unzip
-      elif $(has_yum); then
-        $SUDO yum install -y curl unzip
-      else
-        fatal "Could not find apt-get or yum. Cannot install dependencies on this OS"
-        exit 1
-      fi
-    fi
-  fi
+  $SUDO sha256sum ${BIN_DIR}/vault ${VAULT_CONFIG_DIR}/* ${VAULT_SERVICE_FILE} ${VAULT_DROPIN_DIR}/* 2>&1 || true
 }
 
+{{template "dependencies" .}}
+{{template "releases" .}}
+{{template "state" .}}
+{{template "health" .}}
 download_and_install() {
   if [ -f "${TMP_DIR}/vault.zip" ]; then
+    VAULT_RELEASE_DIR=$(releases_dir vault)/package-$(sha256sum "${TMP_DIR}/vault.zip" | cut -c1-12)
+  else
+    VAULT_RELEASE_DIR=$(releases_dir vault)/${VAULT_VERSION}
+  fi
+  $SUDO mkdir --parents ${VAULT_RELEASE_DIR}
+
+  if [ -f "${TMP_DIR}/vault" ]; then
+    info "Installing uploaded Vault binary"
+    $SUDO cp "${TMP_DIR}/vault" "${VAULT_RELEASE_DIR}/vault.new"
+    $SUDO chmod 0755 "${VAULT_RELEASE_DIR}/vault.new"
+    $SUDO mv -f "${VAULT_RELEASE_DIR}/vault.new" "${VAULT_RELEASE_DIR}/vault"
+    $SUDO setcap cap_ipc_lock=+ep "${VAULT_RELEASE_DIR}/vault"
+  elif [ -f "${TMP_DIR}/vault.zip" ]; then
     info "Installing uploaded Vault package"
-    $SUDO unzip -qq -o "$TMP_DIR/vault.zip" -d $BIN_DIR
-    $SUDO setcap cap_ipc_lock=+ep "${BIN_DIR}/vault"
+    $SUDO unzip -qq -o "$TMP_DIR/vault.zip" -d ${VAULT_RELEASE_DIR}
+    $SUDO setcap cap_ipc_lock=+ep "${VAULT_RELEASE_DIR}/vault"
   else
-    if [ -x "${BIN_DIR}/vault" ] && [ "$(${BIN_DIR}/vault version | grep Vault | cut -d' ' -f2)" = "v${VAULT_VERSION}" ]; then
-      info "Vault binary already installed in ${BIN_DIR}, skipping downloading and installing binary"
+    if [ -x "${VAULT_RELEASE_DIR}/vault" ]; then
+      info "Vault ${VAULT_VERSION} already installed in ${VAULT_RELEASE_DIR}, skipping downloading and installing binary"
     else
       info "Downloading vault_${VAULT_VERSION}_linux_${SUFFIX}.zip"
       curl -o "$TMP_DIR/vault_${VAULT_VERSION}_linux_${SUFFIX}.zip" -sfL "https://releases.hashicorp.com/vault/${VAULT_VERSION}/vault_${VAULT_VERSION}_linux_${SUFFIX}.zip"
@@ -117,18 +123,52 @@ download_and_install() {
       sha256sum -c "$TMP_DIR/vault_${VAULT_VERSION}_SHA256SUMS"
 
       info "Unpacking vault_${VAULT_VERSION}_linux_${SUFFIX}.zip"
-      $SUDO unzip -qq -o "$TMP_DIR/vault_${VAULT_VERSION}_linux_${SUFFIX}.zip" -d $BIN_DIR
-      $SUDO setcap cap_ipc_lock=+ep "${BIN_DIR}/vault"
+      $SUDO unzip -qq -o "$TMP_DIR/vault_${VAULT_VERSION}_linux_${SUFFIX}.zip" -d ${VAULT_RELEASE_DIR}
+      $SUDO setcap cap_ipc_lock=+ep "${VAULT_RELEASE_DIR}/vault"
     fi
   fi
 }
 
+# --- stage the uploaded config files on top of the current ones, to validate them before they replace the current files ---
+stage_config() {
+  $SUDO mkdir -m 0700 ${TMP_DIR}/staging
+  trap '$SUDO rm -rf ${TMP_DIR}/staging' EXIT
+
+  VAULT_STAGING_DIR=${TMP_DIR}/staging/vault.d
+  if [ -d "${VAULT_CONFIG_DIR}" ]; then
+    $SUDO cp -a ${VAULT_CONFIG_DIR} ${VAULT_STAGING_DIR}
+  else
+    $SUDO mkdir ${VAULT_STAGING_DIR}
+  fi
+
+  if [ "$(ls -A ${TMP_DIR}/config/)" ]; then
+    if [ -f "${TMP_DIR}/config/{{.ConfigFile}}" ]; then
+      $SUDO rm -f ${VAULT_STAGING_DIR}/vault.hcl ${VAULT_STAGING_DIR}/vault.json
+    fi
+    $SUDO cp ${TMP_DIR}/config/* ${VAULT_STAGING_DIR}
+  fi
+
+  # diagnose reads the certificates the configuration refers to, so the staged configuration refers to the staged certificates
+  if [ -f "${VAULT_STAGING_DIR}/$(basename ${VAULT_CONFIG_FILE})" ]; then
+    $SUDO sed -i "s|${VAULT_CONFIG_DIR}/|${VAULT_STAGING_DIR}/|g" ${VAULT_STAGING_DIR}/$(basename ${VAULT_CONFIG_FILE})
+  fi
+}
+
 create_user_and_config() {
-  if $(id vault >/dev/null 2>&1); then
-    info "User 'vault' already exists, will not create again"
+  if $(getent group ${VAULT_GROUP} >/dev/null 2>&1); then
+    info "Group '${VAULT_GROUP}' already exists, will not create again"
   else
-    info "Creating user named 'vault'"
-    $SUDO useradd --system --home ${VAULT_CONFIG_DIR} --shell /bin/false vault
+    info "Creating group named '${VAULT_GROUP}'"
+    add_system_group ${VAULT_GROUP}
+    record_account vault group ${VAULT_GROUP}
+  fi
+
+  if $(id ${VAULT_USER} >/dev/null 2>&1); then
+    info "User '${VAULT_USER}' already exists, will not create again"
+  else
+    info "Creating user named '${VAULT_USER}'"
+    add_system_user ${VAULT_USER} ${VAULT_GROUP} ${VAULT_CONFIG_DIR}
+    record_account vault user ${VAULT_USER}
   fi
 
   $SUDO mkdir --parents ${VAULT_DATA_DIR}
@@ -136,15 +176,51 @@ create_user_and_config() {
 
   if [ "$(ls -A ${TMP_DIR}/config/)" ]; then
     info "Copying configuration files"
+    if [ -f "${TMP_DIR}/config/{{.ConfigFile}}" ]; then
+      $SUDO rm -f ${VAULT_CONFIG_DIR}/vault.hcl ${VAULT_CONFIG_DIR}/vault.json
+    fi
     $SUDO cp ${TMP_DIR}/config/* ${VAULT_CONFIG_DIR}
   fi
 
-  $SUDO chown --recursive vault:vault /opt/vault
-  $SUDO chown --recursive vault:vault /etc/vault.d
+  if [ -f "${VAULT_CONFIG_DIR}/vault.env" ]; then
+    $SUDO chmod 0600 ${VAULT_CONFIG_DIR}/vault.env
+  fi
+
+  $SUDO chown --recursive ${VAULT_USER}:${VAULT_GROUP} ${VAULT_DATA_DIR}
+  $SUDO chown --recursive ${VAULT_USER}:${VAULT_GROUP} ${VAULT_CONFIG_DIR}
 }
 
-# --- write systemd service file ---
-create_systemd_service_file() {
+# --- validate the staged configuration with the installed binary, before it replaces the current configuration ---
+validate_config() {
+  [ "${SKIP_VALIDATE}" = true ] && return
+
+  info "Validating Vault configuration"
+  OUTPUT=$($SUDO ${VAULT_RELEASE_DIR}/vault operator diagnose -config=${VAULT_STAGING_DIR}/$(basename ${VAULT_CONFIG_FILE}) 2>&1) && STATUS=0 || STATUS=$?
+
+  # diagnose exits with status 2 when it only reports warnings
+  if [ "${STATUS}" -ne 0 ] && [ "${STATUS}" -ne 2 ]; then
+    echo "${OUTPUT}"
+    fatal "Vault configuration is invalid, the current configuration files are left untouched"
+  fi
+}
+
+# --- write service file for the detected init system ---
+create_service_file() {
+  SERVICE_NAME=vault
+  SERVICE_DESCRIPTION="HashiCorp Vault - A tool for managing secrets"
+  SERVICE_BIN=${BIN_DIR}/vault
+  SERVICE_ARGS="server -config=${VAULT_CONFIG_FILE}{{if .ServiceArgs}} {{.ServiceArgs}}{{end}}"
+  SERVICE_USER=${VAULT_USER}
+  SERVICE_GROUP=${VAULT_GROUP}
+  SERVICE_ENV_FILE=${VAULT_CONFIG_DIR}/vault.env
+  SERVICE_STOP_SIGNAL=INT
+  SERVICE_NOFILE=65536
+
+  if [ "${INIT_SYSTEM}" != systemd ]; then
+    create_init_script ${VAULT_SERVICE_FILE}
+    return
+  fi
+
   info "Adding systemd service file ${VAULT_SERVICE_FILE}"
   $SUDO tee ${VAULT_SERVICE_FILE} >/dev/null <<EOF
 [Unit]
@@ -155,8 +231,9 @@ After=network-online.target
 StartLimitIntervalSec=60
 StartLimitBurst=3
 [Service]
-User=vault
-Group=vault
+EnvironmentFile=-${SERVICE_ENV_FILE}
+User=${VAULT_USER}
+Group=${VAULT_GROUP}
 ProtectSystem=full
 ProtectHome=read-only
 PrivateTmp=yes
@@ -166,7 +243,7 @@ AmbientCapabilities=CAP_IPC_LOCK
 Capabilities=CAP_IPC_LOCK+ep
 CapabilityBoundingSet=CAP_SYSLOG CAP_IPC_LOCK
 NoNewPrivileges=yes
-ExecStart=/usr/local/bin/vault server -config=/etc/vault.d/vault.hcl
+ExecStart=${SERVICE_BIN} ${SERVICE_ARGS}
 ExecReload=/bin/kill -s HUP \$MAINPID
 KillMode=process
 KillSignal=SIGINT
@@ -183,13 +260,29 @@ WantedBy=multi-user.target
 EOF
 }
 
-# --- startup systemd service ---
-systemd_enable_and_start() {
+# --- write systemd drop-in files, the drop-in generated by hashi-up is replaced on every installation ---
+create_systemd_dropins() {
+  if [ "${INIT_SYSTEM}" != systemd ]; then
+    if [ -d "${TMP_DIR}/systemd" ] && [ "$(ls -A ${TMP_DIR}/systemd/)" ]; then
+      info "Skipping systemd drop-in files, the service is managed by ${INIT_SYSTEM}"
+    fi
+    return
+  fi
+
+  $SUDO rm -f ${VAULT_DROPIN_DIR}/hashi-up.conf
+  if [ -d "${TMP_DIR}/systemd" ] && [ "$(ls -A ${TMP_DIR}/systemd/)" ]; then
+    info "Adding systemd drop-in files to ${VAULT_DROPIN_DIR}"
+    $SUDO mkdir --parents ${VAULT_DROPIN_DIR}
+    $SUDO cp ${TMP_DIR}/systemd/* ${VAULT_DROPIN_DIR}
+  fi
+}
+
+# --- enable and start the service ---
+enable_and_start_service() {
   [ "${SKIP_ENABLE}" = true ] && return
 
-  info "Enabling systemd service"
-  $SUDO systemctl enable ${VAULT_SERVICE_FILE} >/dev/null
-  $SUDO systemctl daemon-reload >/dev/null
+  info "Enabling ${INIT_SYSTEM} service"
+  service_enable vault
 
   [ "${SKIP_START}" = true ] && return
 
@@ -199,8 +292,10 @@ systemd_enable_and_start() {
     return
   fi
 
-  info "Starting systemd service"
-  $SUDO systemctl restart vault
+  info "Starting ${INIT_SYSTEM} service"
+  service_action restart vault
+
+  wait_for_health vault
 
   return 0
 }
@@ -209,9 +304,13 @@ cd $TMP_DIR
 
 setup_env
 setup_verify_arch
-verify_system
-install_dependencies
-create_user_and_config
+[ -f "${TMP_DIR}/vault" ] || install_dependencies curl unzip
+stage_config
 download_and_install
-create_systemd_service_file
-systemd_enable_and_start
\ No newline at end of file
+validate_config
+create_user_and_config
+activate_release vault ${VAULT_RELEASE_DIR} ${VAULT_CONFIG_DIR} ${BIN_DIR}
+create_service_file
+create_systemd_dropins
+enable_and_start_service
+save_install_record vault
\ No newline at end of file
@@ -0,0 +1,67 @@
+#!/bin/bash
+set -e
+
+info() {
+  echo '[INFO] ->' "$@"
+}
+
+fatal() {
+  echo '[ERROR] ->' "$@"
+  exit 1
+}
+
+{{template "init" .}}
+setup_env() {
+  SUDO=sudo
+  if [ "$(id -u)" -eq 0 ]; then
+    SUDO=
+  else
+    if [ ! -z "$SUDO_PASS" ]; then
+      echo $SUDO_PASS | sudo -S true
+      echo ""
+    fi
+  fi
+
+  INIT_SYSTEM={{.InitSystem}}
+  detect_init_system
+
+  LOG_FILE=/var/log/$SERVICE.log
+}
+
+# --- print the journal of the service, filtered with journalctl itself ---
+journal_logs() {
+  set -- --unit $SERVICE --no-pager --lines ${LOG_LINES}
+  [ -n "${LOG_SINCE}" ] && set -- "$@" --since "${LOG_SINCE}"
+  [ -n "${LOG_GREP}" ] && set -- "$@" --grep "${LOG_GREP}"
+  [ "${LOG_FOLLOW}" = true ] && set -- "$@" --follow
+  $SUDO journalctl "$@"
+}
+
+# --- print the log file written by the OpenRC or SysV init script of the service ---
+file_logs() {
+  if ! $SUDO test -f ${LOG_FILE}; then
+    fatal "Could not find log file ${LOG_FILE}"
+  fi
+
+  if [ -n "${LOG_SINCE}" ]; then
+    info "Filtering on time is only supported with systemd, showing all lines" >&2
+  fi
+
+  if [ -n "${LOG_GREP}" ]; then
+    $SUDO grep -E -e "${LOG_GREP}" ${LOG_FILE} | tail -n ${LOG_LINES} || true
+    if [ "${LOG_FOLLOW}" = true ]; then
+      $SUDO tail -n 0 -F ${LOG_FILE} | grep --line-buffered -E -e "${LOG_GREP}"
+    fi
+  elif [ "${LOG_FOLLOW}" = true ]; then
+    $SUDO tail -n ${LOG_LINES} -F ${LOG_FILE}
+  else
+    $SUDO tail -n ${LOG_LINES} ${LOG_FILE}
+  fi
+}
+
+setup_env
+if [ "${INIT_SYSTEM}" = systemd ]; then
+  journal_logs
+else
+  file_logs
+fi
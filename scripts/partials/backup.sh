@@ -0,0 +1,22 @@
+{{define "backup"}}
+# --- print the name of the systemd units taking the scheduled snapshots of the given product ---
+backup_unit() {
+  echo "hashi-up-$1-backup"
+}
+
+# --- print the path of the job taking a snapshot of the given product ---
+backup_job() {
+  echo "$(state_dir)/$1-backup.sh"
+}
+
+# --- stop and remove the scheduled snapshots of the given product, the snapshots already taken are kept ---
+remove_backup_schedule() {
+  local unit=$(backup_unit $1)
+  [ -f /etc/systemd/system/${unit}.timer ] || return 0
+
+  info "Removing scheduled snapshots of $1"
+  $SUDO systemctl disable --now ${unit}.timer >/dev/null 2>&1 || true
+  $SUDO rm -f /etc/systemd/system/${unit}.timer /etc/systemd/system/${unit}.service $(backup_job $1)
+  $SUDO systemctl daemon-reload
+}
+{{end}}
@@ -0,0 +1,111 @@
+{{define "cli"}}
+# --- verify a configuration file of the service matches the given extended regular expression ---
+config_matches() {
+  $SUDO grep -q -r -E "$1" ${CONFIG_DIR} 2>/dev/null
+}
+
+# --- prepare the environment of the given product CLI: load the environment file of the service, e.g. with tokens,
+# and default the address to the local agent, using https when TLS is enabled in the configuration ---
+setup_cli_env() {
+  if $SUDO test -f ${CONFIG_DIR}/$1.env; then
+    # the environment file is a systemd EnvironmentFile, its values are exported as they are instead of evaluated as shell code
+    while IFS= read -r LINE || [ -n "${LINE}" ]; do
+      case "${LINE}" in
+      *=*) ;;
+      *) continue ;;
+      esac
+      KEY=${LINE%%=*}
+      VALUE=${LINE#*=}
+      case "${KEY}" in
+      '' | [0-9]* | *[!A-Za-z0-9_]*) continue ;;
+      esac
+      case "${VALUE}" in
+      \"*\") VALUE=${VALUE#\"}; VALUE=${VALUE%\"} ;;
+      \'*\') VALUE=${VALUE#\'}; VALUE=${VALUE%\'} ;;
+      esac
+      export "${KEY}=${VALUE}"
+    done <<EOF
+$($SUDO cat ${CONFIG_DIR}/$1.env)
+EOF
+  fi
+
+  case "$1" in
+  consul)
+    if [ -z "${CONSUL_HTTP_ADDR}" ] && config_matches 'https"?[[:space:]]*[=:][[:space:]]*8501'; then
+      export CONSUL_HTTP_ADDR=https://127.0.0.1:8501
+      export CONSUL_HTTP_SSL_VERIFY=false
+    fi
+    ;;
+  nomad)
+    if [ -z "${NOMAD_ADDR}" ] && config_matches 'http"?[[:space:]]*[=:][[:space:]]*true'; then
+      export NOMAD_ADDR=https://127.0.0.1:4646
+      export NOMAD_SKIP_VERIFY=true
+    fi
+    ;;
+  vault)
+    if [ -z "${VAULT_ADDR}" ] && config_matches 'tls_cert_file'; then
+      export VAULT_ADDR=https://127.0.0.1:8200
+      export VAULT_SKIP_VERIFY=true
+    elif [ -z "${VAULT_ADDR}" ]; then
+      # the vault CLI defaults to https
+      export VAULT_ADDR=http://127.0.0.1:8200
+    fi
+    ;;
+  esac
+}
+
+# --- request the HTTP API of the local agent of the given product, with the address, token and TLS settings of its CLI ---
+# arguments: product, path
+api_get() {
+  case "$1" in
+  consul)
+    API_ADDR=${CONSUL_HTTP_ADDR:-127.0.0.1:8500}
+    case "${API_ADDR}" in
+    http://* | https://*) ;;
+    *) [ "${CONSUL_HTTP_SSL}" = true ] && API_ADDR=https://${API_ADDR} || API_ADDR=http://${API_ADDR} ;;
+    esac
+    API_HEADER="X-Consul-Token: ${CONSUL_HTTP_TOKEN}"
+    API_CACERT=${CONSUL_CACERT}
+    [ "${CONSUL_HTTP_SSL_VERIFY}" = false ] && API_INSECURE=true || API_INSECURE=false
+    ;;
+  nomad)
+    API_ADDR=${NOMAD_ADDR:-http://127.0.0.1:4646}
+    API_HEADER="X-Nomad-Token: ${NOMAD_TOKEN}"
+    API_CACERT=${NOMAD_CACERT}
+    API_INSECURE=${NOMAD_SKIP_VERIFY:-false}
+    ;;
+  vault)
+    API_ADDR=${VAULT_ADDR}
+    API_HEADER="X-Vault-Token: ${VAULT_TOKEN}"
+    API_CACERT=${VAULT_CACERT}
+    API_INSECURE=${VAULT_SKIP_VERIFY:-false}
+    ;;
+  esac
+
+  OPTS="--silent --fail --max-time 5"
+  [ -n "${API_CACERT}" ] && OPTS="${OPTS} --cacert ${API_CACERT}"
+  case "${API_INSECURE}" in
+  true | 1) OPTS="${OPTS} --insecure" ;;
+  esac
+  $SUDO curl ${OPTS} --header "${API_HEADER}" "${API_ADDR}$2"
+}
+
+# --- save or restore a snapshot of the given product with its CLI ---
+# arguments: product, save or restore, snapshot file
+snapshot() {
+  case "$1" in
+  consul)
+    ${BIN_DIR}/consul snapshot $2 $3
+    ;;
+  nomad)
+    ${BIN_DIR}/nomad operator snapshot $2 $3
+    ;;
+  vault)
+    ${BIN_DIR}/vault operator raft snapshot $2 $3
+    ;;
+  *)
+    fatal "Snapshots are not supported for $1"
+    ;;
+  esac
+}
+{{end}}
@@ -0,0 +1,43 @@
+{{define "dependencies"}}
+# --- install packages with the package manager available on the target ---
+install_packages() {
+  if [ -x "$(command -v apt-get)" ]; then
+    if ! $SUDO apt-get install -y "$@"; then
+      info "Updating package index"
+      $SUDO apt-get update
+      $SUDO apt-get install -y "$@"
+    fi
+  elif [ -x "$(command -v dnf)" ]; then
+    $SUDO dnf install -y "$@"
+  elif [ -x "$(command -v yum)" ]; then
+    $SUDO yum install -y "$@"
+  elif [ -x "$(command -v zypper)" ]; then
+    $SUDO zypper --non-interactive install "$@"
+  elif [ -x "$(command -v apk)" ]; then
+    $SUDO apk add --no-cache "$@"
+  elif [ -x "$(command -v pacman)" ]; then
+    $SUDO pacman -Sy --noconfirm --needed "$@"
+  else
+    fatal "Could not find apt-get, dnf, yum, zypper, apk or pacman. Cannot install $* on this OS"
+  fi
+}
+
+# --- install the given commands when missing, or verify they are available when SKIP_DEPENDENCIES is set ---
+install_dependencies() {
+  MISSING=""
+  for dependency in "$@"; do
+    if ! [ -x "$(command -v ${dependency})" ]; then
+      MISSING="${MISSING} ${dependency}"
+    fi
+  done
+
+  [ -z "${MISSING}" ] && return
+
+  if [ "${SKIP_DEPENDENCIES}" = true ]; then
+    fatal "Missing required commands:${MISSING}"
+  fi
+
+  info "Installing${MISSING}"
+  install_packages ${MISSING}
+}
+{{end}}
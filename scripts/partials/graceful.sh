@@ -0,0 +1,104 @@
+{{define "graceful"}}
+consul_leave() {
+  info "Leaving the Consul cluster"
+  $1 leave || info "Could not leave the Consul cluster gracefully, stopping anyway"
+}
+
+nomad_drain() {
+  if $1 node status -self >/dev/null 2>&1; then
+    info "Draining the Nomad client, with a deadline of ${DRAIN_DEADLINE}"
+    $1 node drain -self -enable -yes -deadline ${DRAIN_DEADLINE} || info "Could not drain the Nomad client, stopping anyway"
+  fi
+}
+
+# --- mark the Nomad client eligible again after its service is started, as a drain keeps the node ineligible ---
+nomad_restore_eligibility() {
+  ATTEMPTS=0
+  until INFO=$($1 agent-info 2>/dev/null); do
+    ATTEMPTS=$((ATTEMPTS + 1))
+    if [ ${ATTEMPTS} -ge 30 ]; then
+      info "Could not reach the Nomad agent, verify the eligibility of the client with nomad node status -self"
+      return 0
+    fi
+    sleep 1
+  done
+
+  # only a client has a node to mark eligible
+  echo "${INFO}" | grep -q -E "^client$" || return 0
+
+  ATTEMPTS=0
+  until STATUS=$($1 node status -self 2>/dev/null); do
+    ATTEMPTS=$((ATTEMPTS + 1))
+    if [ ${ATTEMPTS} -ge 30 ]; then
+      info "Could not find the Nomad client node, verify its eligibility with nomad node status -self"
+      return 0
+    fi
+    sleep 1
+  done
+
+  if echo "${STATUS}" | grep -q -E "^Eligibility[[:space:]]*=[[:space:]]*ineligible"; then
+    info "Marking the Nomad client eligible for allocations again"
+    $1 node eligibility -self -enable >/dev/null || info "Could not mark the Nomad client eligible, run nomad node eligibility -self -enable"
+  fi
+}
+
+nomad_leave() {
+  if $1 agent-info 2>/dev/null | grep -q -E "^[[:space:]]*server[[:space:]]*=[[:space:]]*true"; then
+    NAME=$($1 agent-info -t '{{"{{.Member.Name}}"}}' 2>/dev/null) || true
+    PEER=$($1 operator raft list-peers 2>/dev/null | awk -v name="${NAME}" 'NR > 1 && $1 == name { print $2 }')
+    if [ -n "${NAME}" ] && [ -n "${PEER}" ]; then
+      info "Removing the Nomad server from the raft peers"
+      $1 operator raft remove-peer -peer-id "${PEER}" || info "Could not remove the Nomad server from the raft peers, stopping anyway"
+    else
+      info "Could not find the Nomad server in the raft peers, stopping anyway"
+    fi
+  fi
+}
+
+vault_step_down() {
+  if $1 status 2>/dev/null | grep -q -E "HA Mode[[:space:]]+active"; then
+    info "Stepping down the active Vault node"
+    $1 operator step-down || info "Could not step down the active Vault node, stopping anyway"
+  fi
+}
+
+# --- gracefully take the given running product out of its cluster before its service is stopped, unless FORCE is set;
+# a Nomad server only leaves the raft peers when the second argument is leave, i.e. on uninstall, not on a routine stop ---
+graceful_stop() {
+  [ "${FORCE}" = true ] && return 0
+  [ -x "${BIN_DIR}/$1" ] && service_running $1 || return 0
+
+  # prepare the CLI environment in a subshell, so it doesn't change the variables of the script
+  (
+    setup_cli_env $1
+    case "$1" in
+    consul)
+      consul_leave ${BIN_DIR}/$1
+      ;;
+    nomad)
+      nomad_drain ${BIN_DIR}/$1
+      if [ "$2" = leave ]; then
+        nomad_leave ${BIN_DIR}/$1
+      fi
+      ;;
+    vault)
+      vault_step_down ${BIN_DIR}/$1
+      ;;
+    esac
+  )
+}
+
+# --- make the given product available to its cluster again after its service is started, undoing graceful_stop ---
+graceful_start() {
+  [ -x "${BIN_DIR}/$1" ] || return 0
+
+  (
+    setup_cli_env $1
+    case "$1" in
+    nomad)
+      nomad_restore_eligibility ${BIN_DIR}/$1
+      ;;
+    esac
+  )
+}
+{{end}}
@@ -0,0 +1,59 @@
+{{define "health"}}
+# --- request the health endpoint once with curl or wget, using the TLS files when set, and print the response ---
+health_request() {
+  if [ -x "$(command -v curl)" ]; then
+    OPTS="--silent --fail --max-time 5"
+    [ -n "${HEALTH_CA_FILE}" ] && OPTS="${OPTS} --cacert ${HEALTH_CA_FILE}"
+    [ -n "${HEALTH_CERT_FILE}" ] && OPTS="${OPTS} --cert ${HEALTH_CERT_FILE} --key ${HEALTH_KEY_FILE}"
+    [ -z "${HEALTH_CA_FILE}" ] && OPTS="${OPTS} --insecure"
+    $SUDO curl ${OPTS} "${HEALTH_URL}"
+  else
+    OPTS="--quiet --output-document - --timeout 5"
+    [ -n "${HEALTH_CA_FILE}" ] && OPTS="${OPTS} --ca-certificate ${HEALTH_CA_FILE}"
+    [ -n "${HEALTH_CERT_FILE}" ] && OPTS="${OPTS} --certificate ${HEALTH_CERT_FILE} --private-key ${HEALTH_KEY_FILE}"
+    [ -z "${HEALTH_CA_FILE}" ] && OPTS="${OPTS} --no-check-certificate"
+    $SUDO wget ${OPTS} "${HEALTH_URL}"
+  fi
+}
+
+# --- wait until the given service reports healthy, or keeps running when no health endpoint is known, within WAIT_TIMEOUT seconds ---
+wait_for_health() {
+  [ "${WAIT}" = true ] || return 0
+
+  if [ -n "${HEALTH_URL}" ] && ! [ -x "$(command -v curl)" ] && ! [ -x "$(command -v wget)" ]; then
+    info "Could not find curl or wget, only verifying the $1 service keeps running"
+    HEALTH_URL=
+  fi
+
+  if [ -n "${HEALTH_URL}" ]; then
+    info "Waiting for $1 to become healthy at ${HEALTH_URL}"
+  else
+    info "Waiting for the $1 service to keep running"
+  fi
+
+  STARTED=$(date +%s)
+  RUNNING=0
+  while [ $(($(date +%s) - STARTED)) -lt ${WAIT_TIMEOUT} ]; do
+    if [ -n "${HEALTH_URL}" ]; then
+      # a successful response can still report the agent is not ready, e.g. without a leader
+      if BODY=$(health_request 2>/dev/null) && { [ -z "${HEALTH_UNHEALTHY}" ] || [ "${BODY}" != "${HEALTH_UNHEALTHY}" ]; }; then
+        info "$1 is healthy"
+        return 0
+      fi
+    elif service_running $1; then
+      RUNNING=$((RUNNING + 1))
+      if [ ${RUNNING} -ge 3 ]; then
+        info "$1 is running"
+        return 0
+      fi
+    else
+      RUNNING=0
+    fi
+    sleep 2
+  done
+
+  info "Latest log lines of $1:"
+  service_logs $1 20
+  fatal "$1 did not become healthy within ${WAIT_TIMEOUT} seconds"
+}
+{{end}}
@@ -0,0 +1,295 @@
+{{define "init"}}
+# --- detect the init system to use as a process supervisor, unless forced with INIT_SYSTEM ---
+detect_init_system() {
+  case "${INIT_SYSTEM}" in
+  systemd | openrc | sysv) ;;
+  "" | auto)
+    if [ -d /run/systemd/system ]; then
+      INIT_SYSTEM=systemd
+    elif [ -x /sbin/openrc-run ] || [ -x "$(command -v openrc-run)" ]; then
+      INIT_SYSTEM=openrc
+    elif [ -d /etc/init.d ]; then
+      INIT_SYSTEM=sysv
+    else
+      fatal "Can not find systemd, OpenRC or SysV init to use as a process supervisor"
+    fi
+    ;;
+  *)
+    fatal "Unsupported init system ${INIT_SYSTEM}, supported are systemd, openrc and sysv"
+    ;;
+  esac
+
+  if [ "${INIT_SYSTEM}" = systemd ] && ! [ -d /run/systemd ]; then
+    fatal "Can not find systemd to use as a process supervisor"
+  fi
+}
+
+# --- print the path of the service file of the given service ---
+service_file() {
+  if [ "${INIT_SYSTEM}" = systemd ]; then
+    echo "/etc/systemd/system/$1.service"
+  else
+    echo "/etc/init.d/$1"
+  fi
+}
+
+# --- enable the given service at boot ---
+service_enable() {
+  case "${INIT_SYSTEM}" in
+  systemd)
+    $SUDO systemctl daemon-reload >/dev/null
+    $SUDO systemctl enable $1 >/dev/null 2>&1
+    ;;
+  openrc)
+    $SUDO rc-update add $1 default >/dev/null
+    ;;
+  sysv)
+    if [ -x "$(command -v update-rc.d)" ]; then
+      $SUDO update-rc.d $1 defaults >/dev/null
+    elif [ -x "$(command -v chkconfig)" ]; then
+      $SUDO chkconfig --add $1
+      $SUDO chkconfig $1 on
+    else
+      info "Could not find update-rc.d or chkconfig, $1 will not be started at boot"
+    fi
+    ;;
+  esac
+}
+
+# --- disable the given service at boot ---
+service_disable() {
+  case "${INIT_SYSTEM}" in
+  systemd)
+    $SUDO systemctl disable $1 >/dev/null 2>&1 || true
+    $SUDO systemctl daemon-reload
+    ;;
+  openrc)
+    $SUDO rc-update del $1 default >/dev/null 2>&1 || true
+    ;;
+  sysv)
+    if [ -x "$(command -v update-rc.d)" ]; then
+      $SUDO update-rc.d -f $1 remove >/dev/null 2>&1 || true
+    elif [ -x "$(command -v chkconfig)" ]; then
+      $SUDO chkconfig --del $1 >/dev/null 2>&1 || true
+    fi
+    ;;
+  esac
+}
+
+# --- execute an action, like start, stop, restart or reload, on the given service ---
+service_action() {
+  case "${INIT_SYSTEM}" in
+  systemd)
+    $SUDO systemctl $1 $2
+    ;;
+  openrc)
+    $SUDO rc-service $2 $1
+    ;;
+  sysv)
+    $SUDO /etc/init.d/$2 $1
+    ;;
+  esac
+}
+
+# --- verify the given service is running ---
+service_running() {
+  case "${INIT_SYSTEM}" in
+  systemd)
+    $SUDO systemctl is-active --quiet $1
+    ;;
+  openrc)
+    $SUDO rc-service $1 status >/dev/null 2>&1
+    ;;
+  sysv)
+    $SUDO /etc/init.d/$1 status >/dev/null 2>&1
+    ;;
+  esac
+}
+
+# --- print the state of the given service, like active, inactive or failed ---
+service_state() {
+  if [ "${INIT_SYSTEM}" = systemd ]; then
+    $SUDO systemctl is-active $1 || true
+  elif service_running $1; then
+    echo active
+  else
+    echo inactive
+  fi
+}
+
+# --- verify the given service is enabled at boot ---
+service_enabled() {
+  case "${INIT_SYSTEM}" in
+  systemd)
+    $SUDO systemctl is-enabled --quiet $1 2>/dev/null
+    ;;
+  openrc)
+    rc-update show default 2>/dev/null | grep -q -w "$1"
+    ;;
+  sysv)
+    ls /etc/rc[2345].d/S[0-9][0-9]$1 /etc/rc.d/rc[2345].d/S[0-9][0-9]$1 >/dev/null 2>&1
+    ;;
+  esac
+}
+
+# --- print the process id of the given service, when running ---
+service_pid() {
+  if [ "${INIT_SYSTEM}" = systemd ]; then
+    PID=$($SUDO systemctl show --property MainPID $1 2>/dev/null | cut -d= -f2)
+  else
+    PID=$(cat /run/$1.pid /var/run/$1.pid 2>/dev/null | head -n 1)
+  fi
+  if [ -n "${PID}" ] && [ "${PID}" != 0 ] && [ -d "/proc/${PID}" ]; then
+    echo ${PID}
+  fi
+}
+
+# --- print the given number of latest log lines of the given service ---
+service_logs() {
+  if [ "${INIT_SYSTEM}" = systemd ]; then
+    $SUDO journalctl --unit $1 --lines $2 --no-pager || true
+  else
+    $SUDO tail -n $2 /var/log/$1.log 2>/dev/null || true
+  fi
+}
+
+# --- write an OpenRC or SysV init script, described by the SERVICE_* variables, to the given file ---
+create_init_script() {
+  info "Adding ${INIT_SYSTEM} service file $1"
+  if [ "${INIT_SYSTEM}" = openrc ]; then
+    $SUDO tee $1 >/dev/null <<EOF
+#!/sbin/openrc-run
+
+description="${SERVICE_DESCRIPTION}"
+command="${SERVICE_BIN}"
+command_args="${SERVICE_ARGS}"
+command_background=true
+command_user="${SERVICE_USER:-root}:${SERVICE_GROUP:-root}"
+pidfile="/run/\${RC_SVCNAME}.pid"
+output_log="/var/log/${SERVICE_NAME}.log"
+error_log="/var/log/${SERVICE_NAME}.log"
+retry="${SERVICE_STOP_SIGNAL}/30/KILL/5"
+rc_ulimit="-n ${SERVICE_NOFILE}"
+extra_started_commands="reload"
+
+depend() {
+  need net
+  after firewall
+}
+
+start_pre() {
+  if [ -f "${SERVICE_ENV_FILE}" ]; then
+    set -a
+    . "${SERVICE_ENV_FILE}"
+    set +a
+  fi
+  checkpath --file --owner "\${command_user}" "\${output_log}"
+}
+
+reload() {
+  ebegin "Reloading \${RC_SVCNAME}"
+  start-stop-daemon --signal HUP --pidfile "\${pidfile}"
+  eend \$?
+}
+EOF
+  else
+    $SUDO tee $1 >/dev/null <<EOF
+#!/bin/sh
+### BEGIN INIT INFO
+# Provides:          ${SERVICE_NAME}
+# Required-Start:    \$network \$remote_fs
+# Required-Stop:     \$network \$remote_fs
+# Default-Start:     2 3 4 5
+# Default-Stop:      0 1 6
+# Short-Description: ${SERVICE_DESCRIPTION}
+### END INIT INFO
+# chkconfig: 2345 95 05
+# description: ${SERVICE_DESCRIPTION}
+
+NAME=${SERVICE_NAME}
+DAEMON=${SERVICE_BIN}
+DAEMON_ARGS="${SERVICE_ARGS}"
+DAEMON_USER=${SERVICE_USER}
+ENV_FILE=${SERVICE_ENV_FILE}
+PIDFILE=/var/run/\${NAME}.pid
+LOGFILE=/var/log/\${NAME}.log
+
+if [ -f "\${ENV_FILE}" ]; then
+  set -a
+  . "\${ENV_FILE}"
+  set +a
+fi
+
+is_running() {
+  [ -f "\${PIDFILE}" ] && kill -0 "\$(cat "\${PIDFILE}")" 2>/dev/null
+}
+
+start() {
+  if is_running; then
+    echo "\${NAME} is already running"
+    return 0
+  fi
+  echo "Starting \${NAME}"
+  ulimit -n ${SERVICE_NOFILE}
+  touch "\${LOGFILE}"
+  if [ -n "\${DAEMON_USER}" ]; then
+    chown "\${DAEMON_USER}" "\${LOGFILE}"
+    su -s /bin/sh -c "\${DAEMON} \${DAEMON_ARGS} >>\${LOGFILE} 2>&1 & echo \\\$!" "\${DAEMON_USER}" >"\${PIDFILE}"
+  else
+    \${DAEMON} \${DAEMON_ARGS} >>"\${LOGFILE}" 2>&1 &
+    echo \$! >"\${PIDFILE}"
+  fi
+}
+
+stop() {
+  if ! is_running; then
+    echo "\${NAME} is not running"
+    rm -f "\${PIDFILE}"
+    return 0
+  fi
+  echo "Stopping \${NAME}"
+  PID=\$(cat "\${PIDFILE}")
+  kill -${SERVICE_STOP_SIGNAL} "\${PID}"
+  i=0
+  while [ \$i -lt 30 ] && is_running; do
+    sleep 1
+    i=\$((i + 1))
+  done
+  if is_running; then
+    kill -KILL "\${PID}"
+  fi
+  rm -f "\${PIDFILE}"
+}
+
+case "\$1" in
+start)
+  start
+  ;;
+stop)
+  stop
+  ;;
+restart)
+  stop
+  start
+  ;;
+reload)
+  is_running && kill -HUP "\$(cat "\${PIDFILE}")"
+  ;;
+status)
+  if is_running; then
+    echo "\${NAME} is running"
+  else
+    echo "\${NAME} is not running"
+    exit 3
+  fi
+  ;;
+*)
+  echo "Usage: \$0 {start|stop|restart|reload|status}"
+  exit 2
+  ;;
+esac
+EOF
+  fi
+  $SUDO chmod 0755 $1
+}
+{{end}}
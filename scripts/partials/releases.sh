@@ -0,0 +1,42 @@
+{{define "releases"}}
+# --- print the directory with the installed releases of the given product ---
+releases_dir() {
+  echo "/opt/hashi-up/$1"
+}
+
+# --- make the given release directory the current release of a product, keeping the previous one for a rollback ---
+# arguments: product, release directory, config directory, bin directory
+activate_release() {
+  RELEASES=$(releases_dir $1)
+  CURRENT=$(readlink ${RELEASES}/current || true)
+
+  # keep a binary installed before the versioned layout was introduced, so it can be rolled back to
+  if [ -z "${CURRENT}" ] && [ -f "$4/$1" ] && ! [ -L "$4/$1" ]; then
+    CURRENT=${RELEASES}/unversioned
+    $SUDO mkdir --parents ${CURRENT}
+    $SUDO cp -a $4/$1 ${CURRENT}/$1
+  fi
+
+  if [ -n "${CURRENT}" ] && [ "${CURRENT}" != "$2" ]; then
+    $SUDO ln -sfn ${CURRENT} ${RELEASES}/previous
+  fi
+  $SUDO ln -sfn $2 ${RELEASES}/current
+
+  $SUDO rm -rf $2/config
+  $SUDO cp -a $3 $2/config
+
+  $SUDO mkdir --parents $4
+  $SUDO ln -sfn ${RELEASES}/current/$1 $4/$1
+
+  PREVIOUS=$(readlink ${RELEASES}/previous || true)
+  for release in $(ls ${RELEASES}); do
+    case "${RELEASES}/${release}" in
+    ${RELEASES}/current | ${RELEASES}/previous | $2 | ${PREVIOUS}) ;;
+    *)
+      info "Removing release ${release}"
+      $SUDO rm -rf ${RELEASES}/${release}
+      ;;
+    esac
+  done
+}
+{{end}}
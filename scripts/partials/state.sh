@@ -0,0 +1,77 @@
+{{define "state"}}
+# --- print the directory where hashi-up keeps the state of the installed products ---
+state_dir() {
+  echo "/etc/hashi-up"
+}
+
+# --- remember a system user or group created for the given product, so uninstall only removes what was created ---
+# arguments: product, user or group, name
+record_account() {
+  $SUDO mkdir --parents $(state_dir)
+  echo "$2=$3" | $SUDO tee -a $(state_dir)/$1.accounts >/dev/null
+}
+
+# --- verify the given command is available to root, whose PATH includes the sbin directories ---
+has_root_command() {
+  $SUDO sh -c "command -v $1" >/dev/null 2>&1
+}
+
+# --- create a system group, with addgroup on distributions without groupadd, like Alpine ---
+add_system_group() {
+  if has_root_command groupadd; then
+    $SUDO groupadd --system $1
+  else
+    $SUDO addgroup -S $1
+  fi
+}
+
+# --- create a system user without a login shell, with adduser on distributions without useradd, like Alpine ---
+# arguments: user, group, home directory
+add_system_user() {
+  if has_root_command useradd; then
+    $SUDO useradd --system --gid $2 --home $3 --shell /bin/false $1
+  else
+    $SUDO adduser -S -D -H -G $2 -h $3 -s /bin/false $1
+  fi
+}
+
+# --- remove a system user, with deluser on distributions without userdel, like Alpine ---
+remove_system_user() {
+  if has_root_command userdel; then
+    $SUDO userdel $1
+  else
+    $SUDO deluser $1
+  fi
+}
+
+# --- remove a system group, with delgroup on distributions without groupdel, like Alpine ---
+remove_system_group() {
+  if has_root_command groupdel; then
+    $SUDO groupdel $1
+  else
+    $SUDO delgroup $1
+  fi
+}
+
+# --- keep the record of the installation of the given product, uploaded by hashi-up next to the other files ---
+save_install_record() {
+  [ -f "${TMP_DIR}/record.json" ] || return 0
+  $SUDO mkdir --parents $(state_dir)
+  $SUDO cp ${TMP_DIR}/record.json $(state_dir)/$1.json
+  $SUDO chmod 0600 $(state_dir)/$1.json
+}
+
+# --- print the configuration file to use, when the configuration is not replaced the installed file is kept, whatever its format ---
+# arguments: product, config directory, configuration file
+installed_config_file() {
+  if [ ! -f "${TMP_DIR}/config/$(basename $3)" ] && ! $SUDO test -f "$3"; then
+    for f in $2/$1.hcl $2/$1.json; do
+      if $SUDO test -f "$f"; then
+        echo "$f"
+        return
+      fi
+    done
+  fi
+  echo "$3"
+}
+{{end}}
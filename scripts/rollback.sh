@@ -0,0 +1,71 @@
+#!/bin/bash
+set -e
+
+info() {
+  echo '[INFO] ->' "$@"
+}
+
+fatal() {
+  echo '[ERROR] ->' "$@"
+  exit 1
+}
+
+{{template "init" .}}
+{{template "releases" .}}
+setup_env() {
+  SUDO=sudo
+  if [ "$(id -u)" -eq 0 ]; then
+    SUDO=
+  else
+    if [ ! -z "$SUDO_PASS" ]; then
+      echo $SUDO_PASS | sudo -S true
+      echo ""
+    fi
+  fi
+
+  INIT_SYSTEM={{.InitSystem}}
+  detect_init_system
+
+  CONFIG_DIR="${CONFIG_DIR:-/etc/$SERVICE.d}"
+  BIN_DIR="${BIN_DIR:-/usr/local/bin}"
+  RELEASES=$(releases_dir $SERVICE)
+}
+
+verify_releases() {
+  CURRENT=$(readlink ${RELEASES}/current || true)
+  PREVIOUS=$(readlink ${RELEASES}/previous || true)
+
+  if [ -z "${PREVIOUS}" ] || ! [ -x "${PREVIOUS}/$SERVICE" ]; then
+    fatal "No previous release of $SERVICE found in ${RELEASES}, nothing to roll back to"
+  fi
+}
+
+restore_previous_release() {
+  info "Rolling back to release $(basename ${PREVIOUS})"
+
+  if [ -n "${CURRENT}" ]; then
+    $SUDO rm -rf ${CURRENT}/config
+    $SUDO cp -a ${CONFIG_DIR} ${CURRENT}/config
+    $SUDO ln -sfn ${CURRENT} ${RELEASES}/previous
+  fi
+  $SUDO ln -sfn ${PREVIOUS} ${RELEASES}/current
+
+  if [ -d "${PREVIOUS}/config" ]; then
+    info "Restoring configuration files of release $(basename ${PREVIOUS})"
+    $SUDO rm -rf ${CONFIG_DIR}
+    $SUDO cp -a ${PREVIOUS}/config ${CONFIG_DIR}
+  fi
+
+  $SUDO mkdir --parents ${BIN_DIR}
+  $SUDO ln -sfn ${RELEASES}/current/$SERVICE ${BIN_DIR}/$SERVICE
+}
+
+restart_service() {
+  info "Restarting ${INIT_SYSTEM} service"
+  service_action restart $SERVICE
+}
+
+setup_env
+verify_releases
+restore_previous_release
+restart_service
@@ -8,7 +8,7 @@ import (
 	"text/template"
 )
 
-//go:embed *.sh
+//go:embed *.sh partials/*.sh
 var content embed.FS
 
 func Open(path string) (fs.File, error) {
@@ -18,7 +18,7 @@ func Open(path string) (fs.File, error) {
 func RenderScript(name string, data interface{}) (io.Reader, error) {
 	var buf bytes.Buffer
 
-	t, err := template.ParseFS(content, name)
+	t, err := template.ParseFS(content, name, "partials/*.sh")
 	if err != nil {
 		return nil, err
 	}
@@ -10,12 +10,9 @@ fatal() {
   exit 1
 }
 
-verify_system() {
-  if ! [ -d /run/systemd ]; then
-    fatal "Can not find systemd to use as a process supervisor"
-  fi
-}
-
+{{template "init" .}}
+{{template "cli" .}}
+{{template "graceful" .}}
 setup_env() {
   SUDO=sudo
   if [ "$(id -u)" -eq 0 ]; then
@@ -26,12 +23,28 @@ setup_env() {
       echo ""
     fi
   fi
+
+  INIT_SYSTEM={{.InitSystem}}
+  detect_init_system
+
+  CONFIG_DIR="${CONFIG_DIR:-/etc/$SERVICE.d}"
+  BIN_DIR="${BIN_DIR:-/usr/local/bin}"
+
+  FORCE={{.Force}}
+  DRAIN_DEADLINE={{.DrainDeadline}}
 }
 
 execute() {
-  $SUDO systemctl $ACTION $SERVICE
+  if [ "$ACTION" = stop ]; then
+    graceful_stop $SERVICE
+  fi
+  service_action $ACTION $SERVICE
+  case "$ACTION" in
+  start | restart)
+    graceful_start $SERVICE
+    ;;
+  esac
 }
 
-verify_system
 setup_env
 execute
\ No newline at end of file
@@ -0,0 +1,35 @@
+#!/bin/bash
+set -e
+
+info() {
+  echo '[INFO] ->' "$@"
+}
+
+fatal() {
+  echo '[ERROR] ->' "$@"
+  exit 1
+}
+
+{{template "cli" .}}
+setup_env() {
+  SUDO=sudo
+  if [ "$(id -u)" -eq 0 ]; then
+    SUDO=
+  else
+    if [ ! -z "$SUDO_PASS" ]; then
+      echo $SUDO_PASS | sudo -S true
+      echo ""
+    fi
+  fi
+
+  CONFIG_DIR="${CONFIG_DIR:-/etc/$SERVICE.d}"
+  BIN_DIR="${BIN_DIR:-/usr/local/bin}"
+
+  if ! [ -x "${BIN_DIR}/$SERVICE" ]; then
+    fatal "Could not find the $SERVICE binary in ${BIN_DIR}"
+  fi
+}
+
+setup_env
+setup_cli_env $SERVICE
+snapshot $SERVICE $ACTION ${SNAPSHOT}
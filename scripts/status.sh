@@ -0,0 +1,74 @@
+#!/bin/bash
+set -e
+
+info() {
+  echo '[INFO] ->' "$@"
+}
+
+fatal() {
+  echo '[ERROR] ->' "$@"
+  exit 1
+}
+
+{{template "init" .}}
+setup_env() {
+  SUDO=sudo
+  if [ "$(id -u)" -eq 0 ]; then
+    SUDO=
+  else
+    if [ ! -z "$SUDO_PASS" ]; then
+      echo $SUDO_PASS | sudo -S true
+      echo ""
+    fi
+  fi
+
+  INIT_SYSTEM={{.InitSystem}}
+  detect_init_system
+
+  CONFIG_DIR="${CONFIG_DIR:-/etc/$SERVICE.d}"
+  BIN_DIR="${BIN_DIR:-/usr/local/bin}"
+}
+
+# --- print the installed binary and its version ---
+report_binary() {
+  if [ -x "${BIN_DIR}/$SERVICE" ]; then
+    echo "installed=true"
+    echo "version=$(${BIN_DIR}/$SERVICE version 2>/dev/null | grep -o -E '[0-9]+\.[0-9]+\.[0-9]+[^ ]*' | head -n 1)"
+  else
+    echo "installed=false"
+  fi
+}
+
+# --- print the state of the service and the uptime of its process ---
+report_service() {
+  echo "init_system=${INIT_SYSTEM}"
+  [ -f "$(service_file $SERVICE)" ] || return 0
+
+  echo "state=$(service_state $SERVICE)"
+  if service_enabled $SERVICE; then
+    echo "enabled=true"
+  else
+    echo "enabled=false"
+  fi
+
+  PID=$(service_pid $SERVICE)
+  if [ -n "${PID}" ]; then
+    UPTIME=$(ps -o etimes= -p ${PID} 2>/dev/null | tr -d ' ')
+    if [ -z "${UPTIME}" ]; then
+      UPTIME=$(($(date +%s) - $(stat -c %Y /proc/${PID})))
+    fi
+    echo "uptime=${UPTIME}"
+  fi
+}
+
+# --- print the hashes of the configuration files ---
+report_config() {
+  for file in $($SUDO find ${CONFIG_DIR} -maxdepth 1 -type f 2>/dev/null | sort); do
+    echo "config=$($SUDO sha256sum ${file} | cut -d' ' -f1) ${file}"
+  done
+}
+
+setup_env
+report_binary
+report_service
+report_config
@@ -10,12 +10,11 @@ fatal() {
   exit 1
 }
 
-verify_system() {
-  if ! [ -d /run/systemd ]; then
-    fatal "Can not find systemd to use as a process supervisor"
-  fi
-}
-
+{{template "init" .}}
+{{template "state" .}}
+{{template "cli" .}}
+{{template "graceful" .}}
+{{template "backup" .}}
 setup_env() {
   SUDO=sudo
   if [ "$(id -u)" -eq 0 ]; then
@@ -27,28 +26,85 @@ setup_env() {
     fi
   fi
 
-  DATA_DIR="/opt/$SERVICE"
-  CONFIG_DIR="/etc/$SERVICE.d"
-  SERVICE_FILE="/etc/systemd/system/$SERVICE.service"
-  BIN_DIR=/usr/local/bin
+  INIT_SYSTEM={{.InitSystem}}
+  detect_init_system
+
+  DATA_DIR="${DATA_DIR:-/opt/$SERVICE}"
+  CONFIG_DIR="${CONFIG_DIR:-/etc/$SERVICE.d}"
+  SERVICE_FILE="$(service_file $SERVICE)"
+  DROPIN_DIR="/etc/systemd/system/$SERVICE.service.d"
+  BIN_DIR="${BIN_DIR:-/usr/local/bin}"
+  ACCOUNTS_FILE="$(state_dir)/$SERVICE.accounts"
+
+  KEEP_DATA={{.KeepData}}
+  KEEP_CONFIG={{.KeepConfig}}
+  FORCE={{.Force}}
+  DRAIN_DEADLINE={{.DrainDeadline}}
 }
 
 stop_and_disable_service() {
-  info "Stopping and disabling systemd service"
-  $SUDO systemctl stop $SERVICE
-  $SUDO systemctl disable $SERVICE
-  $SUDO systemctl daemon-reload
+  if ! [ -f "${SERVICE_FILE}" ]; then
+    info "No ${INIT_SYSTEM} service found, skipping stopping the service"
+    return
+  fi
+
+  graceful_stop $SERVICE leave
+
+  info "Stopping and disabling ${INIT_SYSTEM} service"
+  if service_running $SERVICE; then
+    service_action stop $SERVICE
+  fi
+  service_disable $SERVICE
 }
 
 clean_up() {
   info "Removing installation"
-  $SUDO rm -rf $CONFIG_DIR
-  $SUDO rm -rf $DATA_DIR
+  if [ "${KEEP_CONFIG}" = true ]; then
+    info "Keeping configuration files in ${CONFIG_DIR}"
+  else
+    $SUDO rm -rf $CONFIG_DIR
+    $SUDO rm -f $(state_dir)/$SERVICE.json
+  fi
+  if [ "${KEEP_DATA}" = true ]; then
+    info "Keeping data in ${DATA_DIR}"
+  else
+    $SUDO rm -rf $DATA_DIR
+  fi
   $SUDO rm -rf $SERVICE_FILE
+  $SUDO rm -rf $DROPIN_DIR
+  $SUDO rm -f /var/log/$SERVICE.log
   $SUDO rm -rf $BIN_DIR/$SERVICE
+  $SUDO rm -rf /opt/hashi-up/$SERVICE
+}
+
+# --- remove the system user and group created during installation, unless they still own the kept files ---
+remove_accounts() {
+  [ -f "${ACCOUNTS_FILE}" ] || return 0
+
+  if [ "${KEEP_DATA}" = true ] || [ "${KEEP_CONFIG}" = true ]; then
+    info "Keeping the $SERVICE user and group, as they own the kept files"
+    return
+  fi
+
+  for user in $(sed -n 's/^user=//p' ${ACCOUNTS_FILE}); do
+    if id ${user} >/dev/null 2>&1; then
+      info "Removing user '${user}'"
+      remove_system_user ${user}
+    fi
+  done
+
+  for group in $(sed -n 's/^group=//p' ${ACCOUNTS_FILE}); do
+    if getent group ${group} >/dev/null 2>&1; then
+      info "Removing group '${group}'"
+      remove_system_group ${group} || info "Could not remove group '${group}', it is still in use"
+    fi
+  done
+
+  $SUDO rm -f ${ACCOUNTS_FILE}
 }
 
-verify_system
 setup_env
+remove_backup_schedule $SERVICE
 stop_and_disable_service
-clean_up
\ No newline at end of file
+clean_up
+remove_accounts
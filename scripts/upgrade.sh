@@ -0,0 +1,179 @@
+#!/bin/bash
+set -e
+
+info() {
+  echo '[INFO] ->' "$@"
+}
+
+fatal() {
+  echo '[ERROR] ->' "$@"
+  exit 1
+}
+
+{{template "init" .}}
+{{template "dependencies" .}}
+{{template "releases" .}}
+{{template "health" .}}
+{{template "cli" .}}
+setup_env() {
+  SUDO=sudo
+  if [ "$(id -u)" -eq 0 ]; then
+    SUDO=
+  else
+    if [ ! -z "$SUDO_PASS" ]; then
+      echo $SUDO_PASS | sudo -S true
+      echo ""
+    fi
+  fi
+
+  INIT_SYSTEM={{.InitSystem}}
+  detect_init_system
+
+  CONFIG_DIR="${CONFIG_DIR:-/etc/$SERVICE.d}"
+  BIN_DIR="${BIN_DIR:-/usr/local/bin}"
+
+  TMP_DIR={{.TmpDir}}
+  SKIP_DEPENDENCIES={{.SkipDependencies}}
+  WAIT={{.Wait}}
+  WAIT_TIMEOUT={{.WaitTimeout}}
+  VERSION={{.Version}}
+  ARM_SUFFIX={{.ArmSuffix}}
+  RELEASE_DIR=$(releases_dir $SERVICE)/${VERSION}
+
+  if ! [ -f "$(service_file $SERVICE)" ] || ! [ -x "${BIN_DIR}/$SERVICE" ]; then
+    fatal "$SERVICE is not installed, could not find the $SERVICE service or binary in ${BIN_DIR}"
+  fi
+}
+
+# --- print the installed version, and leader when the local agent is the raft leader of its cluster, follower otherwise ---
+inspect() {
+  echo "version=$(${BIN_DIR}/$SERVICE version 2>/dev/null | grep -o -E '[0-9]+\.[0-9]+\.[0-9]+[^ ]*' | head -n 1)"
+
+  setup_cli_env $SERVICE
+  case "$SERVICE" in
+  consul)
+    ${BIN_DIR}/consul info 2>/dev/null | grep -qE '^[[:space:]]*leader = true' && ROLE=leader
+    ;;
+  nomad)
+    ${BIN_DIR}/nomad agent-info 2>/dev/null | grep -qE '^[[:space:]]*leader = true' && ROLE=leader
+    ;;
+  vault)
+    ${BIN_DIR}/vault status 2>/dev/null | grep -qE '^HA Mode[[:space:]]+active' && ROLE=leader
+    ;;
+  esac
+  echo "role=${ROLE:-follower}"
+}
+
+# --- set arch and suffix, fatal if architecture not supported ---
+setup_verify_arch() {
+  if [ -z "$ARCH" ]; then
+    ARCH=$(uname -m)
+  fi
+  case $ARCH in
+  amd64 | x86_64)
+    SUFFIX=amd64
+    ;;
+  arm64 | aarch64)
+    SUFFIX=arm64
+    ;;
+  arm*)
+    SUFFIX=${ARM_SUFFIX}
+    ;;
+  *)
+    fatal "Unsupported architecture $ARCH"
+    ;;
+  esac
+}
+
+download_and_install() {
+  PACKAGE=${SERVICE}_${VERSION}_linux_${SUFFIX}.zip
+  if [ -x "${RELEASE_DIR}/$SERVICE" ]; then
+    info "$SERVICE ${VERSION} already installed in ${RELEASE_DIR}, skipping downloading binary"
+    return
+  fi
+
+  info "Downloading ${PACKAGE}"
+  curl -o "$TMP_DIR/${PACKAGE}" -sfL "https://releases.hashicorp.com/$SERVICE/${VERSION}/${PACKAGE}"
+
+  info "Downloading ${SERVICE}_${VERSION}_SHA256SUMS"
+  curl -o "$TMP_DIR/${SERVICE}_${VERSION}_SHA256SUMS" -sfL "https://releases.hashicorp.com/$SERVICE/${VERSION}/${SERVICE}_${VERSION}_SHA256SUMS"
+  info "Verifying downloaded ${PACKAGE}"
+  sed -ni '/linux_'"${SUFFIX}"'.zip/p' "$TMP_DIR/${SERVICE}_${VERSION}_SHA256SUMS"
+  (cd $TMP_DIR && sha256sum -c "${SERVICE}_${VERSION}_SHA256SUMS")
+
+  info "Unpacking ${PACKAGE}"
+  $SUDO mkdir --parents ${RELEASE_DIR}
+  $SUDO unzip -qq -o "$TMP_DIR/${PACKAGE}" -d ${RELEASE_DIR}
+
+  # vault locks its memory with mlock, like the binary installed by the install command
+  if [ "$SERVICE" = vault ]; then
+    $SUDO setcap cap_ipc_lock=+ep "${RELEASE_DIR}/vault"
+  fi
+}
+
+# --- verify the top-level healthy field of the health report read from stdin is true, ignoring the health of the servers listed in the report ---
+report_healthy() {
+  tr -d '\n' | sed -E 's/"[Ss]ervers"[[:space:]]*:.*//' | grep -qE "\"$1\"[[:space:]]*:[[:space:]]*true"
+}
+
+# --- verify the cluster reports healthy through the autopilot of the local agent ---
+cluster_healthy() {
+  case "$SERVICE" in
+  consul | nomad)
+    api_get $SERVICE /v1/operator/autopilot/health 2>/dev/null | report_healthy Healthy
+    ;;
+  vault)
+    # a sealed node is not healthy, autopilot is only available with integrated storage and a token allowed to read its state
+    api_get vault /v1/sys/health?standbyok=true\&perfstandbyok=true >/dev/null 2>&1 || return 1
+    STATE=$(api_get vault /v1/sys/storage/raft/autopilot/state 2>/dev/null) || return 0
+    echo "${STATE}" | report_healthy healthy
+    ;;
+  esac
+}
+
+# --- wait until the cluster is healthy again within WAIT_TIMEOUT seconds ---
+wait_for_cluster() {
+  [ "${WAIT}" = true ] || return 0
+  [ "$SERVICE" = boundary ] && return 0
+
+  setup_cli_env $SERVICE
+  info "Waiting for the $SERVICE cluster to become healthy"
+  STARTED=$(date +%s)
+  while [ $(($(date +%s) - STARTED)) -lt ${WAIT_TIMEOUT} ]; do
+    if cluster_healthy; then
+      info "$SERVICE cluster is healthy"
+      return 0
+    fi
+    sleep 2
+  done
+
+  fatal "$SERVICE cluster did not become healthy within ${WAIT_TIMEOUT} seconds"
+}
+
+upgrade() {
+  # curl is required to verify the health of the cluster, even when the binary is already downloaded
+  install_dependencies curl unzip
+
+  if [ "$(readlink $(releases_dir $SERVICE)/current || true)" = "${RELEASE_DIR}" ]; then
+    info "$SERVICE ${VERSION} is already the current release, skipping upgrade"
+    wait_for_cluster
+    return
+  fi
+
+  setup_verify_arch
+  download_and_install
+  activate_release $SERVICE ${RELEASE_DIR} ${CONFIG_DIR} ${BIN_DIR}
+
+  info "Restarting ${INIT_SYSTEM} service"
+  service_action restart $SERVICE
+
+  wait_for_health $SERVICE
+  wait_for_cluster
+}
+
+setup_env
+if [ "$ACTION" = inspect ]; then
+  inspect
+else
+  upgrade
+fi